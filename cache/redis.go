@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/codex"
+	"github.com/shijting/kit/option"
+	"time"
+)
+
+var (
+	//go:embed script/lua/get.lua
+	luaGet string
+	//go:embed script/lua/load_and_delete.lua
+	luaLoadAndDelete string
+)
+
+var _ Cache[string, any] = (*RedisCache[string, any])(nil)
+
+// RedisCache 基于 redis 的分布式缓存，与 LRUCache 实现相同的 Cache 接口，便于在本地缓存和共享缓存之间切换。
+// key 格式化为 "prefix:key" 保存为 redis 字符串，值使用 Serializer 编码，默认使用 JSON。
+type RedisCache[K comparable, V any] struct {
+	cli        redis.Cmdable
+	prefix     string
+	serializer Serializer
+	// onError Get 读取或者解码失败时调用，Get 无法返回错误
+	onError func(error)
+}
+
+// NewRedisCache 创建 RedisCache 实例，默认 key 前缀为 "cache"
+func NewRedisCache[K comparable, V any](cli redis.Cmdable, opts ...option.Option[RedisCache[K, V]]) *RedisCache[K, V] {
+	c := &RedisCache[K, V]{
+		cli:        cli,
+		prefix:     "cache",
+		serializer: NewCodexSerializer(codex.NewJson),
+	}
+	option.Options[RedisCache[K, V]](opts).Apply(c)
+	return c
+}
+
+// WithRedisPrefix 设置 key 前缀
+func WithRedisPrefix[K comparable, V any](prefix string) option.Option[RedisCache[K, V]] {
+	return func(t *RedisCache[K, V]) {
+		t.prefix = prefix
+	}
+}
+
+// WithSerializer 设置值的编解码方式
+func WithSerializer[K comparable, V any](s Serializer) option.Option[RedisCache[K, V]] {
+	return func(t *RedisCache[K, V]) {
+		t.serializer = s
+	}
+}
+
+// WithOnError 设置一个回调函数，Get 读取 redis 或者解码失败时调用，此时 Get 返回 false。
+func WithOnError[K comparable, V any](f func(error)) option.Option[RedisCache[K, V]] {
+	return func(t *RedisCache[K, V]) {
+		t.onError = f
+	}
+}
+
+// Get 从 redis 中读取与指定键关联的值。
+// 如果找到键，则返回值和 true，否则返回零值和 false。
+func (r *RedisCache[K, V]) Get(ctx context.Context, key K) (Item[K, V], bool) {
+	item, err := r.eval(ctx, luaGet, key)
+	if err != nil {
+		if err != ErrKeyNotFound && r.onError != nil {
+			r.onError(err)
+		}
+		return Item[K, V]{}, false
+	}
+	return item, true
+}
+
+// Set 在 redis 中添加或更新与指定键关联的值，如果过期时间为0 表示永不过期。
+func (r *RedisCache[K, V]) Set(ctx context.Context, key K, val V, expiration time.Duration) error {
+	data, err := r.serializer.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return r.cli.Set(ctx, r.key(key), data, expiration).Err()
+}
+
+// Delete 从 redis 中删除与指定键关联的值。
+// 如果键不存在，则返回ErrKeyNotFound错误。
+func (r *RedisCache[K, V]) Delete(ctx context.Context, key K) error {
+	n, err := r.cli.Del(ctx, r.key(key)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// LoadAndDelete 原子地从 redis 中读取并删除与指定键关联的值，只有一个调用方能拿到值。
+// 如果键不存在，则返回ErrKeyNotFound错误。
+func (r *RedisCache[K, V]) LoadAndDelete(ctx context.Context, key K) (Item[K, V], error) {
+	return r.eval(ctx, luaLoadAndDelete, key)
+}
+
+// eval 执行返回 {值, 剩余过期时间} 的脚本并解码
+func (r *RedisCache[K, V]) eval(ctx context.Context, script string, key K) (Item[K, V], error) {
+	var item Item[K, V]
+	res, err := r.cli.Eval(ctx, script, []string{r.key(key)}).Slice()
+	if err == redis.Nil {
+		return item, ErrKeyNotFound
+	}
+	if err != nil {
+		return item, err
+	}
+	data, _ := res[0].(string)
+	if err = r.serializer.Unmarshal([]byte(data), &item.Value); err != nil {
+		return item, err
+	}
+	item.Key = key
+	if ttl, _ := res[1].(int64); ttl > 0 {
+		item.Expiration = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+	return item, nil
+}
+
+func (r *RedisCache[K, V]) key(key K) string {
+	return fmt.Sprintf("%s:%v", r.prefix, key)
+}
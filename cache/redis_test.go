@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/codex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func TestRedisCache(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := NewRedisCache[int, user](redis.NewClient(&redis.Options{Addr: s.Addr()}), WithRedisPrefix[int, user]("user"))
+	ctx := context.Background()
+
+	_, ok := c.Get(ctx, 1)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, 1, user{Name: "Tom", Age: 18}, 0))
+	assert.True(t, s.Exists("user:1"))
+	item, ok := c.Get(ctx, 1)
+	require.True(t, ok)
+	assert.Equal(t, Item[int, user]{Key: 1, Value: user{Name: "Tom", Age: 18}}, item)
+
+	start := time.Now()
+	require.NoError(t, c.Set(ctx, 2, user{Name: "Jerry"}, time.Minute))
+	item, ok = c.Get(ctx, 2)
+	require.True(t, ok)
+	assert.Equal(t, "Jerry", item.Value.Name)
+	assert.WithinDuration(t, start.Add(time.Minute), item.Expiration, time.Second)
+	s.FastForward(time.Minute)
+	_, ok = c.Get(ctx, 2)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Delete(ctx, 1))
+	assert.ErrorIs(t, c.Delete(ctx, 1), ErrKeyNotFound)
+	_, err := c.LoadAndDelete(ctx, 1)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestRedisCache_LoadAndDelete(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := NewRedisCache[string, string](redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+
+	// 并发调用时只有一个调用方拿到值
+	var mu sync.Mutex
+	var items []Item[string, string]
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, err := c.LoadAndDelete(ctx, "key")
+			if err == nil {
+				mu.Lock()
+				items = append(items, item)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, items, 1)
+	assert.Equal(t, "key", items[0].Key)
+	assert.Equal(t, "value", items[0].Value)
+	assert.False(t, items[0].Expiration.IsZero())
+	assert.False(t, s.Exists("cache:key"))
+}
+
+func TestRedisCache_OnError(t *testing.T) {
+	s := miniredis.RunT(t)
+	var errs []error
+	c := NewRedisCache[string, int](redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		WithOnError[string, int](func(err error) {
+			errs = append(errs, err)
+		}))
+	ctx := context.Background()
+
+	require.NoError(t, s.Set("cache:key", "not a number"))
+	_, ok := c.Get(ctx, "key")
+	assert.False(t, ok)
+	_, ok = c.Get(ctx, "missing")
+	assert.False(t, ok)
+	assert.Len(t, errs, 1)
+
+	s.Close()
+	_, ok = c.Get(ctx, "key")
+	assert.False(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Error(t, c.Set(ctx, "key", 1, 0))
+}
+
+func TestRedisCache_WithSerializer(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := NewRedisCache[string, string](redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		WithSerializer[string, string](rawSerializer{}))
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key", "value", 0))
+	val, err := s.Get("cache:key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+	item, ok := c.Get(ctx, "key")
+	require.True(t, ok)
+	assert.Equal(t, "value", item.Value)
+}
+
+// rawSerializer 用于测试的 Serializer，直接保存字符串
+type rawSerializer struct{}
+
+func (rawSerializer) Marshal(v any) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func (rawSerializer) Unmarshal(data []byte, v any) error {
+	*v.(*string) = string(data)
+	return nil
+}
+
+func TestCodexSerializer(t *testing.T) {
+	s := NewCodexSerializer(codex.NewJson)
+	data, err := s.Marshal(user{Name: "Tom", Age: 18})
+	require.NoError(t, err)
+	var u user
+	require.NoError(t, s.Unmarshal(data, &u))
+	assert.Equal(t, user{Name: "Tom", Age: 18}, u)
+	assert.Error(t, s.Unmarshal([]byte("{"), &u))
+}
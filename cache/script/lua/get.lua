@@ -0,0 +1,6 @@
+-- 返回 {值, 剩余过期时间（毫秒）}，永不过期时为 -1，key 不存在时返回 nil
+local val = redis.call('GET', KEYS[1])
+if not val then
+    return nil
+end
+return {val, redis.call('PTTL', KEYS[1])}
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- 读取并删除 key，返回 {值, 剩余过期时间（毫秒）}，永不过期时为 -1，key 不存在时返回 nil
+local val = redis.call('GET', KEYS[1])
+if not val then
+    return nil
+end
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return {val, ttl}
\ No newline at end of file
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"bytes"
+	"github.com/shijting/kit/codex"
+	"io"
+)
+
+// Serializer 把缓存的值编码为字节，用于保存到 redis 等外部存储
+type Serializer interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// CodexSerializer 复用 codex 编解码的 Serializer
+type CodexSerializer struct {
+	newCodex func(rw io.ReadWriter) codex.Codex
+}
+
+// NewCodexSerializer 创建 CodexSerializer 实例，例如 NewCodexSerializer(codex.NewJson)
+func NewCodexSerializer(newCodex func(rw io.ReadWriter) codex.Codex) *CodexSerializer {
+	return &CodexSerializer{newCodex: newCodex}
+}
+
+func (c *CodexSerializer) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.newCodex(&buf).Send(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CodexSerializer) Unmarshal(data []byte, v any) error {
+	return c.newCodex(bytes.NewBuffer(data)).Receive(v)
+}
@@ -1,59 +1,189 @@
 package limiter
 
 import (
+	"context"
+	"github.com/shijting/kit/option"
 	"sync"
 	"time"
 )
 
-// Bucket 令牌桶限流器
+// Bucket 令牌桶限流器，令牌按照纳秒精度连续产生，桶中的令牌数可以是小数
 type Bucket struct {
 	// cap 桶容量
-	cap int64
-	// tokens 桶中的令牌数
-	tokens int64
+	cap float64
+	// tokens 桶中的令牌数，有预约时可能为负数
+	tokens float64
 	// rate 令牌产生速率/s
-	rate int64
+	rate float64
 	// 上次执行rate计算的时间
-	last int64
-	mu   sync.Mutex
+	last  time.Time
+	clock Clock
+	mu    sync.Mutex
 }
 
-func NewBucket(cap, rate int64) *Bucket {
+// NewBucket 创建 Bucket 实例，初始时桶是满的。
+// cap: 桶容量，也是允许的最大突发请求数。
+// rate: 每秒产生令牌数量。
+func NewBucket(cap, rate int64, opts ...option.Option[Bucket]) *Bucket {
 	if cap <= 0 || rate <= 0 {
 		panic("cap and rate must be greater than 0")
 	}
-	return &Bucket{cap: cap, tokens: cap, rate: rate}
+	b := &Bucket{cap: float64(cap), tokens: float64(cap), rate: float64(rate), clock: realClock{}}
+	option.Options[Bucket](opts).Apply(b)
+	b.last = b.clock.Now()
+	return b
 }
 
-// add 添加令牌
-//func (b *Bucket) add() {
-//	b.mu.Lock()
-//	defer b.mu.Unlock()
-//
-//	if b.tokens+b.rate < b.cap {
-//		b.tokens += b.rate
-//	} else {
-//		b.tokens = b.cap
-//	}
-//}
+// WithClock 设置时钟
+func WithClock(clock Clock) option.Option[Bucket] {
+	return func(t *Bucket) {
+		t.clock = clock
+	}
+}
 
 // Accept 是否允许通过
 func (b *Bucket) Accept() bool {
+	return b.take(1)
+}
+
+// Allow 是否允许通过，所有 key 共用一个桶
+func (b *Bucket) Allow(ctx context.Context, key string) (bool, error) {
+	return b.take(1), nil
+}
+
+// AllowN 是否允许 n 次请求同时通过，所有 key 共用一个桶
+func (b *Bucket) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	return b.take(float64(n)), nil
+}
+
+// Wait 阻塞直到拿到令牌或者 ctx 结束，ctx 结束时归还预约的令牌
+func (b *Bucket) Wait(ctx context.Context, key string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	delay := b.Reserve()
+	if delay == 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		b.refund(1)
+		return ctx.Err()
+	case <-b.clock.After(delay):
+		return nil
+	}
+}
+
+// Reserve 预约一个令牌，返回需要等待的时间，调用方等待之后才能执行请求
+func (b *Bucket) Reserve() time.Duration {
+	delay, _ := b.ReserveN(1)
+	return delay
+}
+
+// ReserveN 预约 n 个令牌，返回需要等待的时间。
+// n 超过桶容量时永远无法满足，返回 false 且不预约。
+func (b *Bucket) ReserveN(n int) (time.Duration, bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	now := time.Now().Unix()
-	// 计算当前应该添加的令牌数
-	b.tokens += (now - b.last) * b.rate
-	if b.tokens > b.cap {
-		b.tokens = b.cap
+	if float64(n) > b.cap {
+		return 0, false
+	}
+	b.refill()
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0, true
+	}
+	return b.duration(-b.tokens), true
+}
+
+// TakeN 尝试拿走 n 个令牌，返回详细的限流结果，所有 key 共用一个桶
+func (b *Bucket) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	res := &Result{Limit: int(b.cap)}
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		res.Allowed = true
+	} else if float64(n) > b.cap {
+		res.RetryAfter = -1
+	} else {
+		res.RetryAfter = b.duration(float64(n) - b.tokens)
+	}
+	if b.tokens > 0 {
+		res.Remaining = int(b.tokens)
 	}
-	b.last = now
+	res.ResetAfter = b.duration(b.cap - b.tokens)
+	return res, nil
+}
+
+// take 拿走 n 个令牌，令牌不足时不拿
+func (b *Bucket) take(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	if b.tokens <= 0 {
+	b.refill()
+	if b.tokens < n {
 		return false
 	}
 
-	b.tokens--
+	b.tokens -= n
 	return true
 }
+
+// duration 返回产生 tokens 个令牌需要的时间
+func (b *Bucket) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
+
+// RefundN 归还 n 个令牌，所有 key 共用一个桶
+func (b *Bucket) RefundN(ctx context.Context, key string, n int) error {
+	b.refund(float64(n))
+	return nil
+}
+
+// SetLimit 修改桶容量和每秒产生的令牌数，已有的令牌按照旧的速率补充到当前时间后保留，
+// 超过新容量的部分丢弃，因此修改不会重置桶。
+func (b *Bucket) SetLimit(cap, rate int64) {
+	if cap <= 0 || rate <= 0 {
+		panic("cap and rate must be greater than 0")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cap == float64(cap) && b.rate == float64(rate) {
+		return
+	}
+	b.refill()
+	b.cap = float64(cap)
+	b.rate = float64(rate)
+	if b.tokens > b.cap {
+		b.tokens = b.cap
+	}
+}
+
+// refund 归还 n 个令牌
+func (b *Bucket) refund(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens += n
+	if b.tokens > b.cap {
+		b.tokens = b.cap
+	}
+}
+
+// refill 按照距离上次计算经过的时间添加令牌
+func (b *Bucket) refill() {
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.cap {
+			b.tokens = b.cap
+		}
+		b.last = now
+	}
+}
@@ -0,0 +1,138 @@
+package limiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockClock 手动推进的时钟
+type mockClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []mockWaiter
+}
+
+type mockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, mockWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance 推进时钟并唤醒到期的等待者
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// waiting 返回等待中的数量
+func (c *mockClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func TestBucket_Refill(t *testing.T) {
+	clock := newMockClock()
+	b := NewBucket(2, 10, WithClock(clock))
+	ctx := context.Background()
+
+	ok, _ := b.AllowN(ctx, "", 2)
+	assert.True(t, ok)
+	assert.False(t, b.Accept())
+
+	// 每 100ms 产生一个令牌，不足一秒也会补充
+	clock.Advance(50 * time.Millisecond)
+	assert.False(t, b.Accept())
+	clock.Advance(50 * time.Millisecond)
+	assert.True(t, b.Accept())
+	assert.False(t, b.Accept())
+
+	// 补充不会超过桶容量
+	clock.Advance(time.Hour)
+	ok, _ = b.AllowN(ctx, "", 3)
+	assert.False(t, ok)
+	ok, _ = b.AllowN(ctx, "", 2)
+	assert.True(t, ok)
+}
+
+func TestBucket_Reserve(t *testing.T) {
+	clock := newMockClock()
+	b := NewBucket(2, 10, WithClock(clock))
+
+	assert.Equal(t, time.Duration(0), b.Reserve())
+	assert.Equal(t, time.Duration(0), b.Reserve())
+	assert.Equal(t, 100*time.Millisecond, b.Reserve())
+	// 预约会排队，后面的预约等待更久
+	assert.Equal(t, 200*time.Millisecond, b.Reserve())
+	_, ok := b.ReserveN(3)
+	assert.False(t, ok)
+
+	clock.Advance(200 * time.Millisecond)
+	assert.False(t, b.Accept())
+	clock.Advance(100 * time.Millisecond)
+	assert.True(t, b.Accept())
+}
+
+func TestBucket_Wait(t *testing.T) {
+	clock := newMockClock()
+	b := NewBucket(1, 10, WithClock(clock))
+	ctx := context.Background()
+
+	require.NoError(t, b.Wait(ctx, ""))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Wait(ctx, "")
+	}()
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("wait returned too early")
+	default:
+	}
+	clock.Advance(50 * time.Millisecond)
+	assert.NoError(t, <-done)
+
+	// ctx 结束时归还预约的令牌
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		done <- b.Wait(cctx, "")
+	}()
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	clock.Advance(100 * time.Millisecond)
+	assert.True(t, b.Accept())
+}
@@ -0,0 +1,19 @@
+package limiter
+
+import "time"
+
+// Clock 时钟，测试时可以注入假的时钟，避免 sleep
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,165 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"time"
+)
+
+//go:embed script/composite.lua
+var luaComposite string
+
+// CompositeLimiter 同时按照多条规则限流，例如全局、每个租户和每个路由每个 IP 的限制，
+// 任何一条规则拒绝时请求被拒绝，返回结果的 Rule 为触发拒绝的规则名称。
+type CompositeLimiter interface {
+	// TakeN 尝试让 n 次请求通过，keys 依次为每条规则的 key，key 为空字符串时跳过该规则
+	TakeN(ctx context.Context, keys []string, n int) (*Result, error)
+}
+
+var (
+	_ CompositeLimiter = (*Composite)(nil)
+	_ CompositeLimiter = (*RedisComposite)(nil)
+)
+
+// Refunder 可以归还令牌的限流器，组合限流器拒绝请求时归还前面的规则已经拿走的令牌
+type Refunder interface {
+	RefundN(ctx context.Context, key string, n int) error
+}
+
+// LimiterRule 本地组合限流器的规则
+type LimiterRule struct {
+	Name    string
+	Limiter ResultLimiter
+}
+
+// Composite 本地组合限流器，依次调用每条规则的限流器，后面的规则拒绝时归还前面的规则已经拿走的令牌。
+// 不同请求之间不加锁，一次请求拿走令牌到归还之间，并发的请求可能因为这部分令牌被拒绝。
+type Composite struct {
+	rules []LimiterRule
+}
+
+// NewComposite 创建 Composite 实例。
+// 除了最后一条规则，其他规则的限流器都需要实现 Refunder，否则被后面的规则拒绝时令牌无法归还，panic。
+func NewComposite(rules ...LimiterRule) *Composite {
+	for i := 0; i < len(rules)-1; i++ {
+		if _, ok := rules[i].Limiter.(Refunder); !ok {
+			panic(fmt.Sprintf("limiter: rule %q must implement Refunder", rules[i].Name))
+		}
+	}
+	return &Composite{rules: rules}
+}
+
+func (c *Composite) TakeN(ctx context.Context, keys []string, n int) (*Result, error) {
+	if len(keys) != len(c.rules) {
+		return nil, fmt.Errorf("limiter: got %d keys for %d rules", len(keys), len(c.rules))
+	}
+	var tightest *Result
+	for i, rule := range c.rules {
+		if keys[i] == "" {
+			continue
+		}
+		res, err := rule.Limiter.TakeN(ctx, keys[i], n)
+		if err == nil && !res.Allowed {
+			res.Rule = rule.Name
+		}
+		if err != nil || !res.Allowed {
+			c.refund(ctx, keys, i, n)
+			return res, err
+		}
+		if tightest == nil || res.Remaining < tightest.Remaining {
+			tightest = res
+			tightest.Rule = rule.Name
+		}
+	}
+	if tightest == nil {
+		return &Result{Allowed: true}, nil
+	}
+	return tightest, nil
+}
+
+// refund 归还前 end 条规则拿走的令牌
+func (c *Composite) refund(ctx context.Context, keys []string, end, n int) {
+	for i := 0; i < end; i++ {
+		if keys[i] != "" {
+			_ = c.rules[i].Limiter.(Refunder).RefundN(ctx, keys[i], n)
+		}
+	}
+}
+
+// Rule 分布式组合限流器的规则，每条规则是一个令牌桶
+type Rule struct {
+	Name string
+	// Capacity 桶容量，也是允许的最大突发请求数
+	Capacity int
+	// Rate 每秒产生令牌数量
+	Rate int
+}
+
+// RedisComposite 基于 redis 的分布式组合限流器，所有规则在一个 lua 脚本中原子地检查，
+// 所有规则都允许时才扣减令牌，任何一条规则拒绝时都不扣减。
+// redis 集群下需要在前缀中使用 hash tag，例如 {limiter}，保证所有规则的 key 位于同一个槽。
+type RedisComposite struct {
+	prefix string
+	cli    redis.Cmdable
+	rules  []Rule
+}
+
+func NewRedisComposite(cli redis.Cmdable, rules []Rule, opts ...option.Option[RedisComposite]) *RedisComposite {
+	limiter := &RedisComposite{
+		cli:    cli,
+		prefix: "composite-limiter",
+		rules:  rules,
+	}
+
+	option.Options[RedisComposite](opts).Apply(limiter)
+
+	return limiter
+}
+
+func WithRedisCompositePrefix(prefix string) option.Option[RedisComposite] {
+	return func(t *RedisComposite) {
+		t.prefix = prefix
+	}
+}
+
+func (c *RedisComposite) TakeN(ctx context.Context, keys []string, n int) (*Result, error) {
+	if len(keys) != len(c.rules) {
+		return nil, fmt.Errorf("limiter: got %d keys for %d rules", len(keys), len(c.rules))
+	}
+	redisKeys := make([]string, 0, len(keys))
+	rules := make([]Rule, 0, len(keys))
+	args := []any{time.Now().UnixMilli(), n}
+	for i, rule := range c.rules {
+		if keys[i] == "" {
+			continue
+		}
+		redisKeys = append(redisKeys, fmt.Sprintf("%s:%s:%s", c.prefix, rule.Name, keys[i]))
+		rules = append(rules, rule)
+		args = append(args, rule.Capacity, rule.Rate)
+	}
+	if len(rules) == 0 {
+		return &Result{Allowed: true}, nil
+	}
+
+	res, err := c.cli.Eval(ctx, luaComposite, redisKeys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	// lua 的序号从 1 开始
+	rule := rules[res[1]-1]
+	result := &Result{
+		Allowed:    res[0] == 1,
+		Limit:      rule.Capacity,
+		Remaining:  int(res[2]),
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+		ResetAfter: time.Duration(res[4]) * time.Millisecond,
+		Rule:       rule.Name,
+	}
+	if res[3] < 0 {
+		result.RetryAfter = -1
+	}
+	return result, nil
+}
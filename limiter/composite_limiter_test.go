@@ -0,0 +1,180 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCompositeLimiter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		newLimiter func(t *testing.T) CompositeLimiter
+	}{
+		{
+			name: "local",
+			newLimiter: func(t *testing.T) CompositeLimiter {
+				return NewComposite(
+					LimiterRule{Name: "global", Limiter: NewGCRALimiter(1, time.Minute, 3)},
+					LimiterRule{Name: "user", Limiter: NewIPTokenBucketLimiter(10, WithTokenBucket(2, 1))},
+				)
+			},
+		},
+		{
+			name: "redis",
+			newLimiter: func(t *testing.T) CompositeLimiter {
+				s := miniredis.RunT(t)
+				rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+				return NewRedisComposite(rdb, []Rule{
+					{Name: "global", Capacity: 3, Rate: 1},
+					{Name: "user", Capacity: 2, Rate: 1},
+				})
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			l := tc.newLimiter(t)
+
+			res, err := l.TakeN(ctx, []string{"global", "alice"}, 1)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			// 允许时结果为剩余额度最少的规则
+			assert.Equal(t, "user", res.Rule)
+			assert.Equal(t, 1, res.Remaining)
+
+			// alice 的额度不够，由 user 规则拒绝，global 规则拿走的令牌被归还
+			res, err = l.TakeN(ctx, []string{"global", "alice"}, 2)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Equal(t, "user", res.Rule)
+
+			res, err = l.TakeN(ctx, []string{"global", "bob"}, 2)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, "global", res.Rule)
+			assert.Equal(t, 0, res.Remaining)
+
+			// 全局额度用完，由 global 规则拒绝
+			res, err = l.TakeN(ctx, []string{"global", "carol"}, 1)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Equal(t, "global", res.Rule)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+			// 跳过 key 为空的规则
+			res, err = l.TakeN(ctx, []string{"", "alice"}, 1)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, "user", res.Rule)
+
+			_, err = l.TakeN(ctx, []string{"alice"}, 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewComposite(t *testing.T) {
+	// 最后一条规则不需要归还令牌
+	assert.NotPanics(t, func() {
+		NewComposite(
+			LimiterRule{Name: "global", Limiter: NewGCRALimiter(1, time.Minute, 3)},
+			LimiterRule{Name: "user", Limiter: NewSlidingWindowLimiter(2, time.Minute)},
+		)
+	})
+	assert.Panics(t, func() {
+		NewComposite(
+			LimiterRule{Name: "global", Limiter: NewSlidingWindowLimiter(2, time.Minute)},
+			LimiterRule{Name: "user", Limiter: NewGCRALimiter(1, time.Minute, 3)},
+		)
+	})
+}
+
+// blockingLimiter key 为 "slow" 的请求阻塞到 release 关闭，模拟较慢的 redis 往返
+type blockingLimiter struct {
+	*Bucket
+	release chan struct{}
+}
+
+func (b *blockingLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	if key == "slow" {
+		<-b.release
+	}
+	return b.Bucket.TakeN(ctx, key, n)
+}
+
+func TestComposite_Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	l := NewComposite(
+		LimiterRule{Name: "user", Limiter: &blockingLimiter{Bucket: NewBucket(10, 1), release: release}},
+		LimiterRule{Name: "global", Limiter: NewGCRALimiter(1, time.Minute, 10)},
+	)
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		_, _ = l.TakeN(ctx, []string{"slow", "global"}, 1)
+		close(done)
+	}()
+
+	// 较慢的请求不会阻塞其他请求
+	res, err := l.TakeN(ctx, []string{"fast", "global"}, 1)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	close(release)
+	<-done
+}
+
+func TestGinCompositeMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	l := NewRedisComposite(rdb, []Rule{
+		{Name: "global", Capacity: 10, Rate: 1},
+		{Name: "tenant", Capacity: 5, Rate: 1},
+		{Name: "route-ip", Capacity: 1, Rate: 1},
+	}, WithRedisCompositePrefix("{limiter}"))
+
+	r := gin.New()
+	r.Use(GinCompositeMiddleware(l, []GinKeyFunc{
+		GinKeyGlobal(),
+		GinKeyByHeader("X-Tenant"),
+		GinKeyJoin(GinKeyByRoute(), GinKeyByIP()),
+	}, WithRejectHandler(func(ctx *gin.Context, res *Result) {
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"rule": res.Rule})
+	})))
+	r.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "first", path: "/users/1", wantCode: http.StatusOK},
+		{name: "same route", path: "/users/2", wantCode: http.StatusTooManyRequests, wantBody: `{"rule":"route-ip"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("X-Tenant", "acme")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+	assert.True(t, s.Exists("{limiter}:tenant:acme"))
+	assert.True(t, s.Exists("{limiter}:route-ip:GET /users/:id:192.0.2.1"))
+}
@@ -0,0 +1,141 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimitExceeded 正在执行的请求数达到并发限制
+var ErrConcurrencyLimitExceeded = errors.New("limiter: concurrency limit exceeded")
+
+// ConcurrencyLimiter 自适应并发限制器，限制同时执行的请求数，
+// 并根据请求的延迟和失败情况通过 LimitAlgorithm 调整限制，在下游过载之前主动减载。
+type ConcurrencyLimiter struct {
+	algorithm LimitAlgorithm
+	clock     Clock
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+}
+
+// NewConcurrencyLimiter 创建 ConcurrencyLimiter 实例，初始并发限制为 20，algorithm 为 nil 时 panic
+func NewConcurrencyLimiter(algorithm LimitAlgorithm, opts ...option.Option[ConcurrencyLimiter]) *ConcurrencyLimiter {
+	if algorithm == nil {
+		panic("algorithm must not be nil")
+	}
+	c := &ConcurrencyLimiter{
+		algorithm: algorithm,
+		clock:     realClock{},
+		limit:     20,
+	}
+	option.Options[ConcurrencyLimiter](opts).Apply(c)
+	return c
+}
+
+// WithInitialLimit 设置初始并发限制
+func WithInitialLimit(limit int) option.Option[ConcurrencyLimiter] {
+	return func(t *ConcurrencyLimiter) {
+		t.limit = limit
+	}
+}
+
+// WithConcurrencyClock 设置时钟
+func WithConcurrencyClock(clock Clock) option.Option[ConcurrencyLimiter] {
+	return func(t *ConcurrencyLimiter) {
+		t.clock = clock
+	}
+}
+
+// Limit 返回当前的并发限制
+func (c *ConcurrencyLimiter) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// Inflight 返回正在执行的请求数
+func (c *ConcurrencyLimiter) Inflight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inflight
+}
+
+// Acquire 获取执行许可，达到并发限制时返回 false。
+// 获取成功后必须调用 Token 的 OnSuccess、OnDropped 或者 OnIgnore 之一释放许可。
+func (c *ConcurrencyLimiter) Acquire() (*Token, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight >= c.limit {
+		return nil, false
+	}
+	c.inflight++
+	return &Token{limiter: c, start: c.clock.Now(), inflight: c.inflight}, true
+}
+
+// Do 获取许可后执行 fn，达到并发限制时返回 ErrConcurrencyLimitExceeded。
+// fn 返回 context.DeadlineExceeded 时视为过载，返回其他错误时不参与调整限制。
+func (c *ConcurrencyLimiter) Do(fn func() error) error {
+	token, ok := c.Acquire()
+	if !ok {
+		return ErrConcurrencyLimitExceeded
+	}
+	err := fn()
+	token.done(err)
+	return err
+}
+
+// release 释放许可，update 为 true 时根据延迟调整限制
+func (c *ConcurrencyLimiter) release(t *Token, update, dropped bool) {
+	rtt := c.clock.Now().Sub(t.start)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if update {
+		c.limit = c.algorithm.Update(c.limit, t.inflight, rtt, dropped)
+	}
+}
+
+// Token 执行许可，只能释放一次
+type Token struct {
+	limiter  *ConcurrencyLimiter
+	start    time.Time
+	inflight int
+	once     sync.Once
+}
+
+// done 根据 fn 返回的错误释放许可，context.DeadlineExceeded 视为过载，其他错误不参与调整限制
+func (t *Token) done(err error) {
+	switch {
+	case err == nil:
+		t.OnSuccess()
+	case errors.Is(err, context.DeadlineExceeded):
+		t.OnDropped()
+	default:
+		t.OnIgnore()
+	}
+}
+
+// OnSuccess 请求成功，根据延迟调整限制
+func (t *Token) OnSuccess() {
+	t.once.Do(func() {
+		t.limiter.release(t, true, false)
+	})
+}
+
+// OnDropped 请求因为过载失败，例如超时，减小限制
+func (t *Token) OnDropped() {
+	t.once.Do(func() {
+		t.limiter.release(t, true, true)
+	})
+}
+
+// OnIgnore 请求的结果不能反映负载，例如参数错误，只释放许可不调整限制
+func (t *Token) OnIgnore() {
+	t.once.Do(func() {
+		t.limiter.release(t, false, false)
+	})
+}
@@ -0,0 +1,210 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shijting/kit/codex"
+	"github.com/shijting/kit/netx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(WithAIMDRange(2, 10), WithAIMDTimeout(time.Second))
+	testCases := []struct {
+		name     string
+		limit    int
+		inflight int
+		rtt      time.Duration
+		dropped  bool
+		want     int
+	}{
+		{name: "increase", limit: 4, inflight: 2, rtt: time.Millisecond, want: 5},
+		{name: "low utilization", limit: 4, inflight: 1, rtt: time.Millisecond, want: 4},
+		{name: "max", limit: 10, inflight: 10, rtt: time.Millisecond, want: 10},
+		{name: "dropped", limit: 10, inflight: 10, dropped: true, want: 9},
+		{name: "timeout", limit: 10, inflight: 1, rtt: 2 * time.Second, want: 9},
+		{name: "min", limit: 2, inflight: 1, dropped: true, want: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, a.Update(tc.limit, tc.inflight, tc.rtt, tc.dropped))
+		})
+	}
+}
+
+func TestVegas(t *testing.T) {
+	v := NewVegas(WithVegasRange(1, 100))
+	// 第一次更新记录无负载时的延迟，没有排队，快速增大
+	assert.Equal(t, 26, v.Update(20, 20, 10*time.Millisecond, false))
+	// 排队的请求数在 alpha 和 beta 之间，不变
+	assert.Equal(t, 20, v.Update(20, 20, 12*time.Millisecond, false))
+	// 排队少，缓慢增大
+	assert.Equal(t, 21, v.Update(20, 20, 11*time.Millisecond, false))
+	// 延迟升高，排队多，减小
+	assert.Equal(t, 19, v.Update(20, 20, 20*time.Millisecond, false))
+	assert.Equal(t, 19, v.Update(20, 20, 10*time.Millisecond, true))
+	// 并发利用率低时不调整
+	assert.Equal(t, 20, v.Update(20, 5, 20*time.Millisecond, false))
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	clock := newMockClock()
+	l := NewConcurrencyLimiter(NewAIMD(WithAIMDTimeout(time.Second)), WithInitialLimit(2), WithConcurrencyClock(clock))
+
+	t1, ok := l.Acquire()
+	require.True(t, ok)
+	t2, ok := l.Acquire()
+	require.True(t, ok)
+	_, ok = l.Acquire()
+	assert.False(t, ok)
+	assert.Equal(t, 2, l.Inflight())
+	assert.ErrorIs(t, l.Do(func() error { return nil }), ErrConcurrencyLimitExceeded)
+
+	// 满负载下请求成功，增大限制
+	t1.OnSuccess()
+	t1.OnDropped()
+	assert.Equal(t, 3, l.Limit())
+	assert.Equal(t, 1, l.Inflight())
+
+	// 延迟超过 timeout，减小限制
+	clock.Advance(2 * time.Second)
+	t2.OnSuccess()
+	assert.Equal(t, 2, l.Limit())
+
+	err := l.Do(func() error { return context.DeadlineExceeded })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, l.Limit())
+	// 其他错误不调整限制
+	errBiz := errors.New("biz error")
+	assert.ErrorIs(t, l.Do(func() error { return errBiz }), errBiz)
+	assert.Equal(t, 1, l.Limit())
+	assert.Equal(t, 0, l.Inflight())
+}
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	assert.Panics(t, func() {
+		NewConcurrencyLimiter(nil)
+	})
+}
+
+func TestGinConcurrencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewConcurrencyLimiter(NewAIMD(), WithInitialLimit(1))
+	r := gin.New()
+	r.Use(GinConcurrencyMiddleware(l, WithRejectHandler(func(ctx *gin.Context, res *Result) {
+		ctx.AbortWithStatus(http.StatusServiceUnavailable)
+	})))
+	r.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	r.GET("/error", func(ctx *gin.Context) {
+		ctx.Status(http.StatusBadGateway)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, l.Limit())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/error", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, 1, l.Limit())
+
+	token, ok := l.Acquire()
+	require.True(t, ok)
+	defer token.OnIgnore()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 1, l.Inflight())
+}
+
+func TestNetxConcurrencyHandler(t *testing.T) {
+	l := NewConcurrencyLimiter(NewAIMD(WithAIMDRange(1, 1)), WithInitialLimit(1))
+	handled := make(chan string, 10)
+	rejected := make(chan string, 10)
+	h := NetxConcurrencyHandler(l, func(s *netx.Session, msg string) error {
+		handled <- msg
+		return nil
+	}, func(s *netx.Session, msg string) {
+		rejected <- msg
+	})
+
+	conn, peer := net.Pipe()
+	sess := netx.NewSession(codex.NewJson(conn), conn)
+	done := make(chan struct{})
+	go func() {
+		h.HandleSession(sess)
+		close(done)
+	}()
+	client := codex.NewJson(peer)
+
+	require.NoError(t, client.Send("first"))
+	assert.Equal(t, "first", <-handled)
+	require.Eventually(t, func() bool {
+		return l.Inflight() == 0
+	}, time.Second, time.Millisecond)
+
+	// 占满并发限制，消息被减载
+	token, ok := l.Acquire()
+	require.True(t, ok)
+	require.NoError(t, client.Send("second"))
+	assert.Equal(t, "second", <-rejected)
+	token.OnIgnore()
+
+	require.NoError(t, client.Send("third"))
+	assert.Equal(t, "third", <-handled)
+
+	require.NoError(t, peer.Close())
+	<-done
+}
+
+func TestNetxConcurrencyHandler_Concurrent(t *testing.T) {
+	l := NewConcurrencyLimiter(NewAIMD(WithAIMDRange(2, 2)), WithInitialLimit(2))
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	h := NetxConcurrencyHandler(l, func(s *netx.Session, msg string) error {
+		if msg == "slow" {
+			<-release
+		}
+		handled <- msg
+		return nil
+	}, nil)
+
+	conn, peer := net.Pipe()
+	sess := netx.NewSession(codex.NewJson(conn), conn)
+	done := make(chan struct{})
+	go func() {
+		h.HandleSession(sess)
+		close(done)
+	}()
+	client := codex.NewJson(peer)
+
+	// 较慢的消息不会阻塞同一个会话后面的消息
+	require.NoError(t, client.Send("slow"))
+	require.NoError(t, client.Send("fast"))
+	assert.Equal(t, "fast", <-handled)
+	require.Eventually(t, func() bool {
+		return l.Inflight() == 1
+	}, time.Second, time.Millisecond)
+
+	// 会话关闭后等待正在处理的消息结束
+	require.NoError(t, peer.Close())
+	select {
+	case <-done:
+		t.Fatal("handler returned before slow message finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	assert.Equal(t, "slow", <-handled)
+	assert.Equal(t, 0, l.Inflight())
+}
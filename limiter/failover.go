@@ -0,0 +1,230 @@
+package limiter
+
+import (
+	"context"
+	"github.com/shijting/kit/option"
+	"log"
+	"sync"
+	"time"
+)
+
+// Logger 日志接口，默认使用标准库 log，*log.Logger 实现了该接口
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// FailurePolicy 限流器出错时（例如 redis 不可用）的处理策略
+type FailurePolicy int
+
+const (
+	// FailOpen 放行请求
+	FailOpen FailurePolicy = iota
+	// FailClosed 拒绝请求
+	FailClosed
+	// FailFallback 使用本地限流器，限流效果从全局变为单个节点
+	FailFallback
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailOpen:
+		return "fail-open"
+	case FailClosed:
+		return "fail-closed"
+	case FailFallback:
+		return "fallback"
+	default:
+		return "unknown"
+	}
+}
+
+// localMaxKeys 本地限流器最多保存的 key 数量
+const localMaxKeys = 10000
+
+// Localizer 可以创建相同参数的本地限流器的分布式限流器
+type Localizer interface {
+	// Local 返回相同参数的本地限流器
+	Local() ResultLimiter
+}
+
+// FailoverLimiter 包装分布式限流器，出错时按照 FailurePolicy 处理，不再返回错误。
+// 连续出错达到阈值时熔断，熔断期间不再调用分布式限流器，直接按照策略处理；
+// 熔断结束后放行一次调用探测，成功则恢复，失败则继续熔断。
+type FailoverLimiter struct {
+	primary  ResultLimiter
+	fallback ResultLimiter
+	policy   FailurePolicy
+	logger   Logger
+	clock    Clock
+	// threshold 触发熔断的连续失败次数
+	threshold int
+	// openTimeout 熔断持续的时间
+	openTimeout time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewFailoverLimiter 创建 FailoverLimiter 实例。
+// policy 为 FailFallback 时，默认使用 primary 实现的 Localizer 创建本地限流器，
+// 也可以通过 WithFallback 指定，两者都没有时 panic。
+func NewFailoverLimiter(primary Limiter, policy FailurePolicy, opts ...option.Option[FailoverLimiter]) *FailoverLimiter {
+	f := &FailoverLimiter{
+		primary:     asResultLimiter(primary),
+		policy:      policy,
+		logger:      log.Default(),
+		clock:       realClock{},
+		threshold:   5,
+		openTimeout: 5 * time.Second,
+	}
+	option.Options[FailoverLimiter](opts).Apply(f)
+	if f.policy == FailFallback && f.fallback == nil {
+		l, ok := primary.(Localizer)
+		if !ok {
+			panic("fallback limiter is required")
+		}
+		f.fallback = l.Local()
+	}
+	return f
+}
+
+// WithFallback 设置 FailFallback 策略使用的本地限流器
+func WithFallback(l Limiter) option.Option[FailoverLimiter] {
+	return func(t *FailoverLimiter) {
+		t.fallback = asResultLimiter(l)
+	}
+}
+
+// WithCircuit 设置连续失败 threshold 次之后熔断 openTimeout
+func WithCircuit(threshold int, openTimeout time.Duration) option.Option[FailoverLimiter] {
+	return func(t *FailoverLimiter) {
+		t.threshold = threshold
+		t.openTimeout = openTimeout
+	}
+}
+
+// WithFailoverLogger 设置记录错误的日志
+func WithFailoverLogger(logger Logger) option.Option[FailoverLimiter] {
+	return func(t *FailoverLimiter) {
+		t.logger = logger
+	}
+}
+
+// WithFailoverClock 设置时钟
+func WithFailoverClock(clock Clock) option.Option[FailoverLimiter] {
+	return func(t *FailoverLimiter) {
+		t.clock = clock
+	}
+}
+
+func (f *FailoverLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return f.AllowN(ctx, key, 1)
+}
+
+func (f *FailoverLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := f.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+func (f *FailoverLimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, f.clock, func(ctx context.Context) (*Result, error) {
+		return f.TakeN(ctx, key, 1)
+	})
+}
+
+func (f *FailoverLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	if !f.allowRequest() {
+		return f.fail(ctx, key, n)
+	}
+	res, err := f.primary.TakeN(ctx, key, n)
+	if err != nil {
+		// 调用方取消的请求不是限流器的故障
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f.onFailure(err)
+		return f.fail(ctx, key, n)
+	}
+	f.onSuccess()
+	return res, nil
+}
+
+// Open 是否处于熔断状态
+func (f *FailoverLimiter) Open() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clock.Now().Before(f.openUntil)
+}
+
+// allowRequest 是否调用分布式限流器，熔断结束后只放行一次探测
+func (f *FailoverLimiter) allowRequest() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures < f.threshold {
+		return true
+	}
+	now := f.clock.Now()
+	if now.Before(f.openUntil) {
+		return false
+	}
+	// 探测期间其他请求仍然按照熔断处理
+	f.openUntil = now.Add(f.openTimeout)
+	return true
+}
+
+func (f *FailoverLimiter) onSuccess() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures >= f.threshold {
+		f.logger.Printf("limiter: circuit closed")
+	}
+	f.failures = 0
+	f.openUntil = time.Time{}
+}
+
+func (f *FailoverLimiter) onFailure(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures++
+	f.logger.Printf("limiter: %v, %s", err, f.policy)
+	if f.failures == f.threshold {
+		f.openUntil = f.clock.Now().Add(f.openTimeout)
+		f.logger.Printf("limiter: circuit opened for %s", f.openTimeout)
+	}
+}
+
+// fail 按照策略处理分布式限流器不可用的请求
+func (f *FailoverLimiter) fail(ctx context.Context, key string, n int) (*Result, error) {
+	switch f.policy {
+	case FailClosed:
+		return &Result{RetryAfter: f.openTimeout}, nil
+	case FailFallback:
+		return f.fallback.TakeN(ctx, key, n)
+	default:
+		return &Result{Allowed: true}, nil
+	}
+}
+
+// asResultLimiter 把不返回详细结果的限流器适配为 ResultLimiter，结果中只有 Allowed
+func asResultLimiter(l Limiter) ResultLimiter {
+	if rl, ok := l.(ResultLimiter); ok {
+		return rl
+	}
+	return resultLimiter{Limiter: l}
+}
+
+type resultLimiter struct {
+	Limiter
+}
+
+func (r resultLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	allowed, err := r.AllowN(ctx, key, n)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Allowed: allowed}, nil
+}
@@ -0,0 +1,146 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// brokenLimiter 模拟 redis 不可用的限流器
+type brokenLimiter struct {
+	err   error
+	calls int
+}
+
+func (b *brokenLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return b.AllowN(ctx, key, 1)
+}
+
+func (b *brokenLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	b.calls++
+	return b.err == nil, b.err
+}
+
+func (b *brokenLimiter) Wait(ctx context.Context, key string) error {
+	_, err := b.AllowN(ctx, key, 1)
+	return err
+}
+
+// logs 记录日志的 Logger
+type logs []string
+
+func (l *logs) Printf(format string, args ...any) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+func TestFailoverLimiter_Policy(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      FailurePolicy
+		wantAllowed []bool
+	}{
+		{name: "fail open", policy: FailOpen, wantAllowed: []bool{true, true, true}},
+		{name: "fail closed", policy: FailClosed, wantAllowed: []bool{false, false, false}},
+		{name: "fallback", policy: FailFallback, wantAllowed: []bool{true, true, false}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var l logs
+			f := NewFailoverLimiter(&brokenLimiter{err: errors.New("connection refused")}, tc.policy,
+				WithFallback(NewGCRALimiter(1, time.Minute, 2)), WithFailoverLogger(&l))
+			for _, want := range tc.wantAllowed {
+				ok, err := f.Allow(context.Background(), "key")
+				require.NoError(t, err)
+				assert.Equal(t, want, ok)
+			}
+			assert.Contains(t, l[0], "connection refused")
+		})
+	}
+}
+
+func TestFailoverLimiter_Circuit(t *testing.T) {
+	clock := newMockClock()
+	primary := &brokenLimiter{err: errors.New("connection refused")}
+	var l logs
+	f := NewFailoverLimiter(primary, FailOpen, WithCircuit(2, time.Second),
+		WithFailoverClock(clock), WithFailoverLogger(&l))
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		ok, err := f.Allow(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	// 熔断之后不再调用
+	assert.Equal(t, 2, primary.calls)
+	assert.True(t, f.Open())
+
+	// 熔断结束后探测失败，继续熔断
+	clock.Advance(time.Second)
+	_, _ = f.Allow(ctx, "key")
+	_, _ = f.Allow(ctx, "key")
+	assert.Equal(t, 3, primary.calls)
+	assert.True(t, f.Open())
+
+	// 探测成功，恢复调用
+	primary.err = nil
+	clock.Advance(time.Second)
+	_, _ = f.Allow(ctx, "key")
+	_, _ = f.Allow(ctx, "key")
+	assert.Equal(t, 5, primary.calls)
+	assert.False(t, f.Open())
+	assert.Equal(t, "limiter: circuit closed", l[len(l)-1])
+}
+
+func TestSlideWindowIPLimiter_FailurePolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+	s.Close()
+
+	testCases := []struct {
+		name     string
+		opts     []option.Option[SlideWindowIPLimiter]
+		wantCode []int
+	}{
+		{
+			name:     "default",
+			wantCode: []int{http.StatusInternalServerError},
+		},
+		{
+			name:     "fail closed",
+			opts:     []option.Option[SlideWindowIPLimiter]{WithFailurePolicy(FailClosed)},
+			wantCode: []int{http.StatusTooManyRequests},
+		},
+		{
+			name:     "fallback",
+			opts:     []option.Option[SlideWindowIPLimiter]{WithRate(1), WithFailurePolicy(FailFallback)},
+			wantCode: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var l logs
+			r := gin.New()
+			r.Use(NewSlideWindowIPLimiter(rdb, tc.opts...).Build(WithLogger(&l)))
+			r.GET("/", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			for _, code := range tc.wantCode {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				assert.Equal(t, code, w.Code)
+			}
+		})
+	}
+}
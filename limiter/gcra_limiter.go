@@ -0,0 +1,249 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+//go:embed script/gcra.lua
+var luaGCRA string
+
+var (
+	_ ResultLimiter = (*GCRALimiter)(nil)
+	_ ResultLimiter = (*RedisGCRALimiter)(nil)
+)
+
+// GCRALimiter 基于 GCRA（通用信元速率算法）的本地限流器。
+// 每个 key 只保存一个理论到达时间（TAT），效果与令牌桶相同，但是不需要定时补充令牌。
+type GCRALimiter struct {
+	// emission 每个请求占用的时间，即 period / rate
+	emission time.Duration
+	burst    int
+	clock    Clock
+	mu       sync.Mutex
+	tats     map[string]time.Time
+	// sweepAt tats 达到该大小时清理已经恢复满额的 key
+	sweepAt int
+}
+
+// NewGCRALimiter 创建 GCRALimiter 实例。
+// rate: 每个 period 允许的请求数。
+// burst: 允许的最大突发请求数。
+func NewGCRALimiter(rate int, period time.Duration, burst int, opts ...option.Option[GCRALimiter]) *GCRALimiter {
+	if rate <= 0 || period <= 0 || burst <= 0 {
+		panic("rate, period and burst must be greater than 0")
+	}
+	limiter := &GCRALimiter{
+		emission: period / time.Duration(rate),
+		burst:    burst,
+		clock:    realClock{},
+		tats:     make(map[string]time.Time),
+		sweepAt:  1024,
+	}
+	option.Options[GCRALimiter](opts).Apply(limiter)
+	return limiter
+}
+
+// WithGCRAClock 设置时钟
+func WithGCRAClock(clock Clock) option.Option[GCRALimiter] {
+	return func(t *GCRALimiter) {
+		t.clock = clock
+	}
+}
+
+func (g *GCRALimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return g.AllowN(ctx, key, 1)
+}
+
+func (g *GCRALimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := g.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+func (g *GCRALimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, g.clock, func(ctx context.Context) (*Result, error) {
+		return g.TakeN(ctx, key, 1)
+	})
+}
+
+func (g *GCRALimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	tolerance := g.emission * time.Duration(g.burst)
+	increment := g.emission * time.Duration(n)
+	tat, ok := g.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(increment)
+	diff := now.Sub(newTat.Add(-tolerance))
+	if diff < 0 {
+		res := &Result{
+			Limit:      g.burst,
+			Remaining:  int(now.Sub(tat.Add(-tolerance)) / g.emission),
+			RetryAfter: -diff,
+			ResetAfter: tat.Sub(now),
+		}
+		if increment > tolerance {
+			res.RetryAfter = -1
+		}
+		return res, nil
+	}
+
+	g.tats[key] = newTat
+	if len(g.tats) >= g.sweepAt {
+		g.sweep(now)
+	}
+	return &Result{
+		Allowed:    true,
+		Limit:      g.burst,
+		Remaining:  int(diff / g.emission),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// RefundN 归还 n 个请求占用的额度
+func (g *GCRALimiter) RefundN(ctx context.Context, key string, n int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tat, ok := g.tats[key]
+	if !ok {
+		return nil
+	}
+	tat = tat.Add(-g.emission * time.Duration(n))
+	if !tat.After(g.clock.Now()) {
+		delete(g.tats, key)
+		return nil
+	}
+	g.tats[key] = tat
+	return nil
+}
+
+// sweep 删除已经恢复满额的 key，与不存在的 key 等价
+func (g *GCRALimiter) sweep(now time.Time) {
+	for key, tat := range g.tats {
+		if !tat.After(now) {
+			delete(g.tats, key)
+		}
+	}
+	if g.sweepAt < 2*len(g.tats) {
+		g.sweepAt = 2 * len(g.tats)
+	}
+}
+
+// RedisGCRALimiter 基于 redis 的分布式 GCRA 限流器，每个 key 只保存一个理论到达时间，
+// 相比 SlideWindowIPLimiter 每个请求一个有序集合成员，内存占用与请求速率无关。
+// 各节点的时钟偏差会影响限流的精度。
+type RedisGCRALimiter struct {
+	prefix string
+	cli    redis.Cmdable
+	// emission 每个请求占用的时间，即 period / rate
+	emission time.Duration
+	burst    int
+}
+
+// NewRedisGCRALimiter 创建 RedisGCRALimiter 实例。
+// rate: 每个 period 允许的请求数。
+// burst: 允许的最大突发请求数。
+func NewRedisGCRALimiter(cli redis.Cmdable, rate int, period time.Duration, burst int,
+	opts ...option.Option[RedisGCRALimiter]) *RedisGCRALimiter {
+	if rate <= 0 || period <= 0 || burst <= 0 {
+		panic("rate, period and burst must be greater than 0")
+	}
+	limiter := &RedisGCRALimiter{
+		cli:      cli,
+		prefix:   "gcra-limiter",
+		emission: period / time.Duration(rate),
+		burst:    burst,
+	}
+
+	option.Options[RedisGCRALimiter](opts).Apply(limiter)
+
+	return limiter
+}
+
+func WithRedisGCRAPrefix(prefix string) option.Option[RedisGCRALimiter] {
+	return func(t *RedisGCRALimiter) {
+		t.prefix = prefix
+	}
+}
+
+// Local 返回相同参数的本地限流器
+func (g *RedisGCRALimiter) Local() ResultLimiter {
+	return NewGCRALimiter(1, g.emission, g.burst)
+}
+
+func (g *RedisGCRALimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return g.AllowN(ctx, key, 1)
+}
+
+func (g *RedisGCRALimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := g.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+func (g *RedisGCRALimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, realClock{}, func(ctx context.Context) (*Result, error) {
+		return g.TakeN(ctx, key, 1)
+	})
+}
+
+func (g *RedisGCRALimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	// 脚本中的时间单位是微秒
+	emission := g.emission.Microseconds()
+	if emission < 1 {
+		emission = 1
+	}
+	res, err := g.cli.Eval(ctx, luaGCRA, []string{fmt.Sprintf("%s:%s", g.prefix, key)},
+		emission, g.burst, time.Now().UnixMicro(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Allowed:    res[0] == 1,
+		Limit:      g.burst,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Microsecond,
+		ResetAfter: time.Duration(res[3]) * time.Microsecond,
+	}
+	if res[2] < 0 {
+		result.RetryAfter = -1
+	}
+	return result, nil
+}
+
+// waitResult 调用 take 直到允许通过或者 ctx 结束，被拒绝时按照 RetryAfter 等待
+func waitResult(ctx context.Context, clock Clock, take func(ctx context.Context) (*Result, error)) error {
+	for {
+		res, err := take(ctx)
+		if err != nil {
+			return err
+		}
+		if res.Allowed {
+			return nil
+		}
+		if res.RetryAfter < 0 {
+			return ErrExceedCapacity
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(res.RetryAfter):
+		}
+	}
+}
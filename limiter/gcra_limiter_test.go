@@ -0,0 +1,111 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGCRALimiter(t *testing.T) {
+	clock := newMockClock()
+	g := NewGCRALimiter(10, time.Second, 3, WithGCRAClock(clock))
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		advance time.Duration
+		n       int
+		want    Result
+	}{
+		{
+			name: "first",
+			n:    1,
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 100 * time.Millisecond},
+		},
+		{
+			name: "burst",
+			n:    2,
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 300 * time.Millisecond},
+		},
+		{
+			name: "limited",
+			n:    1,
+			want: Result{Limit: 3, Remaining: 0, RetryAfter: 100 * time.Millisecond, ResetAfter: 300 * time.Millisecond},
+		},
+		{
+			name:    "partially recovered",
+			advance: 150 * time.Millisecond,
+			n:       2,
+			want:    Result{Limit: 3, Remaining: 1, RetryAfter: 50 * time.Millisecond, ResetAfter: 150 * time.Millisecond},
+		},
+		{
+			name: "take remaining",
+			n:    1,
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 250 * time.Millisecond},
+		},
+		{
+			name: "exceed burst",
+			n:    4,
+			want: Result{Limit: 3, Remaining: 0, RetryAfter: -1, ResetAfter: 250 * time.Millisecond},
+		},
+		{
+			name:    "fully recovered",
+			advance: time.Second,
+			n:       3,
+			want:    Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 300 * time.Millisecond},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock.Advance(tc.advance)
+			res, err := g.TakeN(ctx, "key", tc.n)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, *res)
+		})
+	}
+
+	// 恢复满额的 key 会被清理
+	g.sweepAt = 1
+	clock.Advance(time.Second)
+	_, err := g.TakeN(ctx, "other", 1)
+	require.NoError(t, err)
+	assert.Len(t, g.tats, 1)
+}
+
+func TestRedisGCRALimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	g := NewRedisGCRALimiter(rdb, 10, time.Second, 3, WithRedisGCRAPrefix("gcra"))
+	ctx := context.Background()
+
+	res, err := g.TakeN(ctx, "key", 3)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Duration(0), res.RetryAfter)
+	assert.InDelta(t, 300*time.Millisecond, res.ResetAfter, float64(20*time.Millisecond))
+	assert.True(t, s.Exists("gcra:key"))
+
+	res, err = g.TakeN(ctx, "key", 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, 100*time.Millisecond, res.RetryAfter, float64(20*time.Millisecond))
+
+	res, err = g.TakeN(ctx, "key", 4)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Duration(-1), res.RetryAfter)
+
+	start := time.Now()
+	require.NoError(t, g.Wait(ctx, "key"))
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+
+	// 恢复满额之后 key 过期
+	s.FastForward(time.Second)
+	assert.False(t, s.Exists("gcra:key"))
+}
@@ -0,0 +1,127 @@
+package limiter
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shijting/kit/netx"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GinKeyFunc 从 gin 请求中提取限流的 key，返回空字符串时不限流
+type GinKeyFunc func(ctx *gin.Context) string
+
+// HTTPKeyFunc 从 http 请求中提取限流的 key，返回空字符串时不限流
+type HTTPKeyFunc func(r *http.Request) string
+
+// SessionKeyFunc 从 netx 会话中提取限流的 key，返回空字符串时不限流
+type SessionKeyFunc func(s *netx.Session) string
+
+// GinKeyByIP 按照客户端 IP 限流
+func GinKeyByIP() GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.ClientIP()
+	}
+}
+
+// GinKeyByHeader 按照请求头的值限流，例如 X-API-Key
+func GinKeyByHeader(name string) GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// GinKeyByUserID 按照用户 ID 限流，用户 ID 由前面的认证中间件通过 ctx.Set(key, userID) 设置
+func GinKeyByUserID(key string) GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		userID, ok := ctx.Get(key)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprint(userID)
+	}
+}
+
+// GinKeyByRoute 按照路由限流，key 为请求方法加路由模板，例如 GET /users/:id
+func GinKeyByRoute() GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.Request.Method + " " + ctx.FullPath()
+	}
+}
+
+// GinKeyGlobal 所有请求使用同一个 key，用于全局限流
+func GinKeyGlobal() GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		return "global"
+	}
+}
+
+// GinKeyJoin 把多个 key 用冒号连接起来，例如按照路由和 IP 限流，任何一个为空时返回空字符串
+func GinKeyJoin(keyFuncs ...GinKeyFunc) GinKeyFunc {
+	return func(ctx *gin.Context) string {
+		keys := make([]string, len(keyFuncs))
+		for i, keyFunc := range keyFuncs {
+			keys[i] = keyFunc(ctx)
+			if keys[i] == "" {
+				return ""
+			}
+		}
+		return strings.Join(keys, ":")
+	}
+}
+
+// HTTPKeyByIP 按照客户端 IP 限流，使用 RemoteAddr，不信任 X-Forwarded-For 等请求头
+func HTTPKeyByIP() HTTPKeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// HTTPKeyByHeader 按照请求头的值限流，例如 X-API-Key
+func HTTPKeyByHeader(name string) HTTPKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// HTTPKeyByUserID 按照用户 ID 限流，用户 ID 由前面的认证中间件保存在请求的 context 中
+func HTTPKeyByUserID(key any) HTTPKeyFunc {
+	return func(r *http.Request) string {
+		userID := r.Context().Value(key)
+		if userID == nil {
+			return ""
+		}
+		return fmt.Sprint(userID)
+	}
+}
+
+// HTTPKeyByRoute 按照路由限流，key 为请求方法加路径
+func HTTPKeyByRoute() HTTPKeyFunc {
+	return func(r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}
+}
+
+// SessionKeyByIP 按照客户端 IP 限流
+func SessionKeyByIP() SessionKeyFunc {
+	return func(s *netx.Session) string {
+		host, _, err := net.SplitHostPort(s.Addr())
+		if err != nil {
+			return s.Addr()
+		}
+		return host
+	}
+}
+
+// SessionKeyByUserID 按照会话绑定的用户 ID（netx.UserIDKey）限流
+func SessionKeyByUserID() SessionKeyFunc {
+	return func(s *netx.Session) string {
+		userID, _ := netx.UserIDKey.Get(s)
+		return userID
+	}
+}
@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"github.com/shijting/kit/option"
+	"math"
+	"time"
+)
+
+// LimitAlgorithm 根据请求的延迟和结果调整并发限制
+type LimitAlgorithm interface {
+	// Update 请求完成时调用，返回新的并发限制。
+	// inflight: 该请求开始时正在执行的请求数（包括它自己）。
+	// dropped: 请求是否因为过载失败，例如超时。
+	Update(limit, inflight int, rtt time.Duration, dropped bool) int
+}
+
+var (
+	_ LimitAlgorithm = (*AIMD)(nil)
+	_ LimitAlgorithm = (*Vegas)(nil)
+)
+
+// AIMD 加性增、乘性减：请求成功且并发利用率超过一半时限制加一，
+// 请求失败或者延迟超过 timeout 时限制乘以 backoff。
+type AIMD struct {
+	min     int
+	max     int
+	backoff float64
+	timeout time.Duration
+}
+
+func NewAIMD(opts ...option.Option[AIMD]) *AIMD {
+	a := &AIMD{
+		min:     1,
+		max:     1000,
+		backoff: 0.9,
+		timeout: 5 * time.Second,
+	}
+	option.Options[AIMD](opts).Apply(a)
+	return a
+}
+
+// WithAIMDRange 设置并发限制的范围
+func WithAIMDRange(min, max int) option.Option[AIMD] {
+	return func(t *AIMD) {
+		t.min = min
+		t.max = max
+	}
+}
+
+// WithAIMDBackoff 设置过载时限制的缩小比例，取值范围 (0, 1)
+func WithAIMDBackoff(backoff float64) option.Option[AIMD] {
+	return func(t *AIMD) {
+		t.backoff = backoff
+	}
+}
+
+// WithAIMDTimeout 设置延迟超过多少时视为过载
+func WithAIMDTimeout(timeout time.Duration) option.Option[AIMD] {
+	return func(t *AIMD) {
+		t.timeout = timeout
+	}
+}
+
+func (a *AIMD) Update(limit, inflight int, rtt time.Duration, dropped bool) int {
+	if dropped || rtt > a.timeout {
+		limit = int(float64(limit) * a.backoff)
+	} else if inflight*2 >= limit {
+		limit++
+	}
+	return clamp(limit, a.min, a.max)
+}
+
+// Vegas 基于延迟的算法，参考 TCP Vegas：用无负载时的最小延迟估计排队的请求数，
+// 排队少时增大限制，排队多时减小限制，在延迟升高但还没有失败时就开始减载。
+type Vegas struct {
+	min int
+	max int
+	// rttNoLoad 观察到的最小延迟，作为无负载时的延迟
+	rttNoLoad time.Duration
+	// probe 每 probe 次更新重新测量一次最小延迟，适应基准延迟的变化
+	probe   int
+	samples int
+}
+
+func NewVegas(opts ...option.Option[Vegas]) *Vegas {
+	v := &Vegas{
+		min:   1,
+		max:   1000,
+		probe: 1000,
+	}
+	option.Options[Vegas](opts).Apply(v)
+	return v
+}
+
+// WithVegasRange 设置并发限制的范围
+func WithVegasRange(min, max int) option.Option[Vegas] {
+	return func(t *Vegas) {
+		t.min = min
+		t.max = max
+	}
+}
+
+// WithVegasProbe 设置每多少次更新重新测量无负载时的延迟
+func WithVegasProbe(probe int) option.Option[Vegas] {
+	return func(t *Vegas) {
+		t.probe = probe
+	}
+}
+
+// Update 不是并发安全的，由 ConcurrencyLimiter 加锁调用
+func (v *Vegas) Update(limit, inflight int, rtt time.Duration, dropped bool) int {
+	v.samples++
+	if v.samples >= v.probe {
+		v.samples = 0
+		v.rttNoLoad = 0
+	}
+	if rtt > 0 && (v.rttNoLoad == 0 || rtt < v.rttNoLoad) {
+		v.rttNoLoad = rtt
+	}
+
+	step := int(math.Max(1, math.Log10(float64(limit))))
+	if dropped {
+		return clamp(limit-step, v.min, v.max)
+	}
+	// 并发利用率低时延迟不能反映容量，不调整
+	if inflight*2 < limit || rtt <= 0 {
+		return limit
+	}
+
+	queue := int(math.Ceil(float64(limit) * (1 - float64(v.rttNoLoad)/float64(rtt))))
+	alpha, beta := 3*step, 6*step
+	switch {
+	case queue <= step:
+		limit += beta
+	case queue < alpha:
+		limit += step
+	case queue > beta:
+		limit -= step
+	}
+	return clamp(limit, v.min, v.max)
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -1,18 +1,68 @@
 package limiter
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
-	"github.com/shijting/kit/cache"
-	"net/http"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
 )
 
-// GinLimiter gin 全局流装饰器
-func GinLimiter(cap, rate int64) func(handler gin.HandlerFunc) gin.HandlerFunc {
-	bucket := NewBucket(cap, rate)
+// Limiter 限流器，key 为限流的维度，例如 IP、用户 ID 或者路由
+type Limiter interface {
+	// Allow 是否允许 key 的一次请求通过
+	Allow(ctx context.Context, key string) (bool, error)
+	// AllowN 是否允许 key 的 n 次请求同时通过
+	AllowN(ctx context.Context, key string, n int) (bool, error)
+	// Wait 阻塞直到 key 的一次请求被允许或者 ctx 结束
+	Wait(ctx context.Context, key string) error
+}
+
+// Result 限流的详细结果，可以用于设置 RateLimit-* 和 Retry-After 响应头
+type Result struct {
+	// Allowed 是否允许通过
+	Allowed bool
+	// Limit 允许的最大突发请求数
+	Limit int
+	// Remaining 当前还允许通过的请求数
+	Remaining int
+	// RetryAfter 被拒绝时需要等待多久才能重试，允许通过时为 0，永远无法满足时为 -1
+	RetryAfter time.Duration
+	// ResetAfter 多久之后恢复到 Limit 个请求的额度
+	ResetAfter time.Duration
+	// Rule 组合限流器拒绝时为触发拒绝的规则名称，允许时为剩余额度最少的规则名称
+	Rule string
+}
+
+// ResultLimiter 可以返回详细限流结果的限流器
+type ResultLimiter interface {
+	Limiter
+	// TakeN 尝试让 key 的 n 次请求通过，返回详细的限流结果
+	TakeN(ctx context.Context, key string, n int) (*Result, error)
+}
+
+var (
+	_ ResultLimiter = (*Bucket)(nil)
+	_ ResultLimiter = (*IPTokenBucketLimiter)(nil)
+	_ ResultLimiter = (*SlideWindowIPLimiter)(nil)
+	_ ResultLimiter = (*RedisTokenBucketLimiter)(nil)
+
+	_ Reconfigurable = (*Bucket)(nil)
+	_ Reconfigurable = (*IPTokenBucketLimiter)(nil)
+	_ Reconfigurable = (*RedisTokenBucketLimiter)(nil)
+)
+
+// GinLimiter gin 全局流装饰器，参数在构建时固定，需要运行时修改时使用 GinBucketLimiter
+func GinLimiter(cap, rate int64, opts ...option.Option[Middleware]) func(handler gin.HandlerFunc) gin.HandlerFunc {
+	return GinBucketLimiter(NewBucket(cap, rate), opts...)
+}
+
+// GinBucketLimiter 使用 bucket 的 gin 全局流装饰器，可以调用 bucket.SetLimit 或者通过 Watcher 在运行时修改参数
+func GinBucketLimiter(bucket *Bucket, opts ...option.Option[Middleware]) func(handler gin.HandlerFunc) gin.HandlerFunc {
+	m := newMiddleware(opts...)
 	return func(handler gin.HandlerFunc) gin.HandlerFunc {
 		return func(ctx *gin.Context) {
-			if !bucket.Accept() {
-				ctx.AbortWithStatus(http.StatusTooManyRequests)
+			if !m.limit(ctx, bucket, "") {
 				return
 			}
 			handler(ctx)
@@ -23,13 +73,13 @@ func GinLimiter(cap, rate int64) func(handler gin.HandlerFunc) gin.HandlerFunc {
 
 // GinQueryLimiter gin api query
 // key: query key example: /api?accept=xx key: accept 有值时才限流
-func GinQueryLimiter(cap, rate int64, key string) func(handler gin.HandlerFunc) gin.HandlerFunc {
+func GinQueryLimiter(cap, rate int64, key string, opts ...option.Option[Middleware]) func(handler gin.HandlerFunc) gin.HandlerFunc {
 	bucket := NewBucket(cap, rate)
+	m := newMiddleware(opts...)
 	return func(handler gin.HandlerFunc) gin.HandlerFunc {
 		return func(ctx *gin.Context) {
 			if ctx.Query(key) != "" {
-				if !bucket.Accept() {
-					ctx.AbortWithStatus(http.StatusTooManyRequests)
+				if !m.limit(ctx, bucket, key) {
 					return
 				}
 			}
@@ -41,36 +91,118 @@ func GinQueryLimiter(cap, rate int64, key string) func(handler gin.HandlerFunc)
 // IPTokenBucketLimiter ip 限流
 // 可以使用redis实现分布式限流
 type IPTokenBucketLimiter struct {
-	cache *cache.LRUCache[string, *Bucket]
+	buckets *Registry[*Bucket]
+	// cap 作为 Limiter 使用时每个 key 的桶容量
+	cap int64
+	// rate 作为 Limiter 使用时每个 key 每秒产生的令牌数
+	rate int64
+	// idleTTL IP 空闲多久之后删除它的令牌桶
+	idleTTL time.Duration
+	// maxIPs 最多保存令牌桶的 IP 数量，Build 创建的中间件各自使用相同的上限
+	maxIPs int
+	mu     sync.RWMutex
 }
 
 // NewIPTokenBucketLimiter 创建 IPTokenBucketLimiter 实例。
-// maxIPs: 最大允许的 IP 数量
-func NewIPTokenBucketLimiter(maxIPs int) *IPTokenBucketLimiter {
-	return &IPTokenBucketLimiter{cache: cache.NewLRUCache[string, *Bucket](maxIPs)}
+// maxIPs: 最多保存令牌桶的 IP 数量，达到上限后新的 IP 的令牌桶保存在溢出区中，溢出区也满时新的 IP 共用兜底的令牌桶，
+// 已有的 IP 不会被淘汰或者重置
+func NewIPTokenBucketLimiter(maxIPs int, opts ...option.Option[IPTokenBucketLimiter]) *IPTokenBucketLimiter {
+	limiter := &IPTokenBucketLimiter{
+		cap:     200,
+		rate:    200,
+		idleTTL: 10 * time.Minute,
+		maxIPs:  maxIPs,
+	}
+	option.Options[IPTokenBucketLimiter](opts).Apply(limiter)
+	limiter.buckets = limiter.newRegistry()
+	return limiter
+}
+
+// newRegistry 创建保存每个 IP 令牌桶的 Registry
+func (i *IPTokenBucketLimiter) newRegistry() *Registry[*Bucket] {
+	return NewRegistry[*Bucket](WithRegistryMaxKeys[*Bucket](i.maxIPs),
+		WithRegistryIdleTTL[*Bucket](i.idleTTL))
+}
+
+// WithIdleTTL 设置 IP 空闲多久之后删除它的令牌桶，应该不小于桶从空到满需要的时间，默认 10 分钟
+func WithIdleTTL(ttl time.Duration) option.Option[IPTokenBucketLimiter] {
+	return func(t *IPTokenBucketLimiter) {
+		t.idleTTL = ttl
+	}
+}
+
+// WithTokenBucket 设置作为 Limiter 使用时每个 key 的桶容量和每秒产生的令牌数
+func WithTokenBucket(cap, rate int64) option.Option[IPTokenBucketLimiter] {
+	return func(t *IPTokenBucketLimiter) {
+		t.cap = cap
+		t.rate = rate
+	}
+}
+
+// SetLimit 修改作为 Limiter 使用时每个 key 的桶容量和每秒产生的令牌数，
+// 已有的令牌桶在下次使用时平滑地调整，不会被重置
+func (i *IPTokenBucketLimiter) SetLimit(cap, rate int64) {
+	if cap <= 0 || rate <= 0 {
+		panic("cap and rate must be greater than 0")
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.cap = cap
+	i.rate = rate
+}
+
+// limit 返回作为 Limiter 使用时每个 key 的桶容量和每秒产生的令牌数
+func (i *IPTokenBucketLimiter) limit() (int64, int64) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cap, i.rate
+}
+
+// keyBucket 返回 key 的令牌桶，不存在时创建，参数变化时调整已有的令牌桶
+func (i *IPTokenBucketLimiter) keyBucket(ctx context.Context, key string) *Bucket {
+	cap, rate := i.limit()
+	bucket := i.buckets.Get(key, func() *Bucket {
+		return NewBucket(cap, rate)
+	})
+	bucket.SetLimit(cap, rate)
+	return bucket
+}
+
+func (i *IPTokenBucketLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return i.AllowN(ctx, key, 1)
+}
+
+func (i *IPTokenBucketLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	return i.keyBucket(ctx, key).AllowN(ctx, key, n)
+}
+
+func (i *IPTokenBucketLimiter) Wait(ctx context.Context, key string) error {
+	return i.keyBucket(ctx, key).Wait(ctx, key)
+}
+
+func (i *IPTokenBucketLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	return i.keyBucket(ctx, key).TakeN(ctx, key, n)
+}
+
+func (i *IPTokenBucketLimiter) RefundN(ctx context.Context, key string, n int) error {
+	return i.keyBucket(ctx, key).RefundN(ctx, key, n)
 }
 
 // Build 返回一个 gin 中间件函数，用于限制 IP 请求频率。
 // cap: 桶容量。
 // rate: 每秒产生令牌数量。
-func (i *IPTokenBucketLimiter) Build(cap, rate int64) func(handler gin.HandlerFunc) gin.HandlerFunc {
+// 每次 Build 创建的中间件各自保存 IP 的令牌桶，参数只作用于该中间件，不影响 i 作为 Limiter 使用时的参数，
+// 也不受 SetLimit 影响。需要运行时修改时使用 GinMiddleware(i, GinKeyByIP()) 并调用 SetLimit。
+func (i *IPTokenBucketLimiter) Build(cap, rate int64, opts ...option.Option[Middleware]) func(handler gin.HandlerFunc) gin.HandlerFunc {
+	buckets := i.newRegistry()
+	m := newMiddleware(opts...)
 	return func(handler gin.HandlerFunc) gin.HandlerFunc {
 		return func(ctx *gin.Context) {
 			ip := ctx.ClientIP()
-			cctx := ctx.Request.Context()
-			item, ok := i.cache.Get(cctx, ip)
-
-			var bucket *Bucket
-
-			if !ok {
-				bucket = NewBucket(cap, rate)
-				i.cache.Set(cctx, ip, bucket, 0)
-			} else {
-				bucket = item.Value
-			}
-
-			if !bucket.Accept() {
-				ctx.AbortWithStatus(http.StatusTooManyRequests)
+			bucket := buckets.Get(ip, func() *Bucket {
+				return NewBucket(cap, rate)
+			})
+			if !m.limit(ctx, bucket, ip) {
 				return
 			}
 
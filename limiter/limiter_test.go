@@ -0,0 +1,309 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		newLimiter func(t *testing.T) Limiter
+	}{
+		{
+			name: "bucket",
+			newLimiter: func(t *testing.T) Limiter {
+				return NewBucket(3, 1)
+			},
+		},
+		{
+			name: "ip token bucket",
+			newLimiter: func(t *testing.T) Limiter {
+				return NewIPTokenBucketLimiter(10, WithTokenBucket(3, 1))
+			},
+		},
+		{
+			name: "slide window",
+			newLimiter: func(t *testing.T) Limiter {
+				s := miniredis.RunT(t)
+				rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+				return NewSlideWindowIPLimiter(rdb, WithRate(3), WithInterval(time.Minute))
+			},
+		},
+		{
+			name: "redis token bucket",
+			newLimiter: func(t *testing.T) Limiter {
+				s := miniredis.RunT(t)
+				rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+				return NewRedisTokenBucketLimiter(rdb, WithRedisBucketCapacity(3), WithRedisBucketRate(1))
+			},
+		},
+		{
+			name: "gcra",
+			newLimiter: func(t *testing.T) Limiter {
+				return NewGCRALimiter(1, time.Second, 3)
+			},
+		},
+		{
+			name: "redis gcra",
+			newLimiter: func(t *testing.T) Limiter {
+				s := miniredis.RunT(t)
+				rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+				return NewRedisGCRALimiter(rdb, 1, time.Second, 3)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			l := tc.newLimiter(t)
+
+			ok, err := l.AllowN(ctx, "key", 2)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = l.AllowN(ctx, "key", 2)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			ok, err = l.Allow(ctx, "key")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = l.Allow(ctx, "key")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			wctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			assert.ErrorIs(t, l.Wait(wctx, "key"), context.DeadlineExceeded)
+		})
+	}
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewIPTokenBucketLimiter(10, WithTokenBucket(1, 1))
+	r := gin.New()
+	r.Use(GinMiddleware(l, GinKeyByHeader("X-API-Key")))
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name     string
+		apiKey   string
+		wantCode int
+	}{
+		{name: "first", apiKey: "a", wantCode: http.StatusOK},
+		{name: "limited", apiKey: "a", wantCode: http.StatusTooManyRequests},
+		{name: "other key", apiKey: "b", wantCode: http.StatusOK},
+		{name: "no key", wantCode: http.StatusOK},
+		{name: "no key again", wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	l := NewIPTokenBucketLimiter(10, WithTokenBucket(1, 1))
+	h := HTTPMiddleware(l, HTTPKeyByIP())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		wantCode   int
+	}{
+		{name: "first", remoteAddr: "10.0.0.1:1234", wantCode: http.StatusOK},
+		{name: "same ip other port", remoteAddr: "10.0.0.1:4321", wantCode: http.StatusTooManyRequests},
+		{name: "other ip", remoteAddr: "10.0.0.2:1234", wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
+
+func TestGinMiddleware_Reject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name        string
+		opts        []option.Option[Middleware]
+		wantCode    int
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{
+			name:     "default",
+			wantCode: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "1",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "10",
+				"Retry-After":         "10",
+			},
+		},
+		{
+			name: "reject handler",
+			opts: []option.Option[Middleware]{
+				WithRejectHandler(func(ctx *gin.Context, res *Result) {
+					ctx.JSON(http.StatusTooManyRequests, gin.H{"retry_after": res.RetryAfter.Seconds()})
+				}),
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"retry_after":10}`,
+			wantHeaders: map[string]string{
+				"Retry-After": "10",
+			},
+		},
+		{
+			name:     "dry run",
+			opts:     []option.Option[Middleware]{WithDryRun()},
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "",
+				"Retry-After":     "",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newMockClock()
+			r := gin.New()
+			r.Use(GinMiddleware(NewGCRALimiter(1, 10*time.Second, 1, WithGCRAClock(clock)), GinKeyByIP(), tc.opts...))
+			r.GET("/", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+			for name, value := range tc.wantHeaders {
+				assert.Equal(t, value, w.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestMiddleware_LimiterError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name     string
+		opts     []option.Option[Middleware]
+		wantCode int
+		wantLog  string
+	}{
+		{
+			name:     "default",
+			wantCode: http.StatusInternalServerError,
+			wantLog:  "limiter: redis down",
+		},
+		{
+			name:     "dry run",
+			opts:     []option.Option[Middleware]{WithDryRun()},
+			wantCode: http.StatusOK,
+			wantLog:  "limiter: dry run, redis down",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &brokenLimiter{err: errors.New("redis down")}
+
+			var ginLogs logs
+			r := gin.New()
+			r.Use(GinMiddleware(l, GinKeyByIP(), append(tc.opts, WithLogger(&ginLogs))...))
+			r.GET("/", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, logs{tc.wantLog}, ginLogs)
+
+			var httpLogs logs
+			h := HTTPMiddleware(l, HTTPKeyByIP(), append(tc.opts, WithLogger(&httpLogs))...)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, logs{tc.wantLog}, httpLogs)
+		})
+	}
+}
+
+func TestSlideWindowIPLimiter_TakeN(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	l := NewSlideWindowIPLimiter(rdb, WithRate(2), WithInterval(time.Second))
+	ctx := context.Background()
+
+	res, err := l.TakeN(ctx, "key", 2)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.ResetAfter)
+
+	res, err = l.TakeN(ctx, "key", 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, time.Second, res.RetryAfter, float64(50*time.Millisecond))
+
+	res, err = l.TakeN(ctx, "key", 3)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), res.RetryAfter)
+}
+
+func TestSlideWindowIPLimiter_SameMillisecond(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	l := NewSlideWindowIPLimiter(rdb, WithRate(3), WithInterval(time.Minute))
+	ctx := context.Background()
+
+	// 同一毫秒内的请求不会被合并成一个有序集合成员
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		ok, err := l.Allow(ctx, "key")
+		require.NoError(t, err)
+		if ok {
+			allowed++
+		}
+	}
+	assert.Equal(t, 3, allowed)
+	members, err := s.ZMembers("ip-limiter:key")
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+}
@@ -0,0 +1,301 @@
+package limiter
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/shijting/kit/netx"
+	"github.com/shijting/kit/option"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RejectHandler 处理被限流的请求，res 为限流器返回的详细结果，限流器不是 ResultLimiter 时为 nil
+type RejectHandler func(ctx *gin.Context, res *Result)
+
+// Middleware gin 限流中间件的配置
+type Middleware struct {
+	onReject RejectHandler
+	// dryRun 只记录会被拒绝的请求，不真正拒绝
+	dryRun bool
+	logger Logger
+}
+
+func newMiddleware(opts ...option.Option[Middleware]) *Middleware {
+	m := &Middleware{
+		onReject: func(ctx *gin.Context, res *Result) {
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+		},
+		logger: log.Default(),
+	}
+	option.Options[Middleware](opts).Apply(m)
+	return m
+}
+
+// WithRejectHandler 设置被限流时的处理函数，例如返回 JSON 格式的错误信息。
+// 处理函数调用之后请求会被中止，只对 gin 中间件生效。
+func WithRejectHandler(handler RejectHandler) option.Option[Middleware] {
+	return func(t *Middleware) {
+		t.onReject = handler
+	}
+}
+
+// WithDryRun 设置为演练模式，会被拒绝的请求只记录日志，仍然放行，也不设置限流响应头，
+// 限流器出错时同样只记录日志并放行，用于上线前观察限流参数是否合适
+func WithDryRun() option.Option[Middleware] {
+	return func(t *Middleware) {
+		t.dryRun = true
+	}
+}
+
+// WithLogger 设置记录限流器错误和演练结果的日志
+func WithLogger(logger Logger) option.Option[Middleware] {
+	return func(t *Middleware) {
+		t.logger = logger
+	}
+}
+
+// GinMiddleware 返回使用 l 限流的 gin 中间件，keyFunc 提取限流的 key
+func GinMiddleware(l Limiter, keyFunc GinKeyFunc, opts ...option.Option[Middleware]) gin.HandlerFunc {
+	m := newMiddleware(opts...)
+	return func(ctx *gin.Context) {
+		key := keyFunc(ctx)
+		if key != "" && !m.limit(ctx, l, key) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// GinCompositeMiddleware 返回使用组合限流器 l 限流的 gin 中间件，
+// keyFuncs 依次提取每条规则的 key，返回空字符串时跳过该规则
+func GinCompositeMiddleware(l CompositeLimiter, keyFuncs []GinKeyFunc, opts ...option.Option[Middleware]) gin.HandlerFunc {
+	m := newMiddleware(opts...)
+	return func(ctx *gin.Context) {
+		keys := make([]string, len(keyFuncs))
+		for i, keyFunc := range keyFuncs {
+			keys[i] = keyFunc(ctx)
+		}
+		res, err := l.TakeN(ctx.Request.Context(), keys, 1)
+		var allowed bool
+		if err == nil {
+			allowed = res.Allowed
+		}
+		if !m.decide(ctx, strings.Join(keys, ","), allowed, res, err) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// limit 使用 l 对 key 限流，返回是否继续处理请求
+func (m *Middleware) limit(ctx *gin.Context, l Limiter, key string) bool {
+	allowed, res, err := take(ctx.Request.Context(), l, key)
+	return m.decide(ctx, key, allowed, res, err)
+}
+
+// decide 根据限流结果处理请求，返回是否继续处理请求
+func (m *Middleware) decide(ctx *gin.Context, key string, allowed bool, res *Result, err error) bool {
+	if err != nil {
+		if m.fail(err) {
+			return true
+		}
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+	if m.shadow(allowed, key) {
+		return true
+	}
+	if res != nil {
+		setHeaders(ctx.Writer.Header(), res)
+	}
+	if !allowed {
+		m.onReject(ctx, res)
+		ctx.Abort()
+		return false
+	}
+	return true
+}
+
+// shadow 演练模式下记录会被拒绝的请求，返回是否为演练模式
+func (m *Middleware) shadow(allowed bool, key string) bool {
+	if !m.dryRun {
+		return false
+	}
+	if !allowed {
+		m.logger.Printf("limiter: dry run, request with key %q would be rejected", key)
+	}
+	return true
+}
+
+// fail 记录限流器的错误，返回是否继续处理请求，只有演练模式下放行
+func (m *Middleware) fail(err error) bool {
+	if m.dryRun {
+		m.logger.Printf("limiter: dry run, %v", err)
+		return true
+	}
+	m.logger.Printf("limiter: %v", err)
+	return false
+}
+
+// take l 是 ResultLimiter 时返回详细的限流结果，否则结果为 nil
+func take(ctx context.Context, l Limiter, key string) (bool, *Result, error) {
+	if rl, ok := l.(ResultLimiter); ok {
+		res, err := rl.TakeN(ctx, key, 1)
+		if err != nil {
+			return false, nil, err
+		}
+		return res.Allowed, res, nil
+	}
+	allowed, err := l.Allow(ctx, key)
+	return allowed, nil, err
+}
+
+// setHeaders 设置 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 响应头，
+// 被拒绝时设置 Retry-After，时间单位都是秒
+// Limit 为 0 表示结果不是限流器给出的，例如 FailoverLimiter 放行或者拒绝的请求，不设置响应头
+func setHeaders(header http.Header, res *Result) {
+	if res.Limit == 0 {
+		return
+	}
+	header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	header.Set("RateLimit-Reset", strconv.FormatInt(seconds(res.ResetAfter), 10))
+	if !res.Allowed && res.RetryAfter >= 0 {
+		header.Set("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+	}
+}
+
+// seconds 向上取整到秒
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// HTTPMiddleware 返回使用 l 限流的 net/http 中间件，keyFunc 提取限流的 key
+func HTTPMiddleware(l Limiter, keyFunc HTTPKeyFunc, opts ...option.Option[Middleware]) func(next http.Handler) http.Handler {
+	m := newMiddleware(opts...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, res, err := take(r.Context(), l, key)
+			if err != nil {
+				if m.fail(err) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if m.shadow(ok, key) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if res != nil {
+				setHeaders(w.Header(), res)
+			}
+			if !ok {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NetxHandler 返回使用 l 限制新会话的 netx.Handler，被限流的会话直接关闭，
+// keyFunc 提取限流的 key，例如 SessionKeyByIP 限制每个 IP 建立连接的速率
+func NetxHandler(l Limiter, keyFunc SessionKeyFunc, handler netx.Handler, opts ...option.Option[Middleware]) netx.Handler {
+	m := newMiddleware(opts...)
+	return netx.HandlerFunc(func(s *netx.Session) {
+		key := keyFunc(s)
+		if key == "" {
+			handler.HandleSession(s)
+			return
+		}
+		ok, err := l.Allow(context.Background(), key)
+		if err != nil {
+			if m.fail(err) {
+				handler.HandleSession(s)
+			} else {
+				s.Close()
+			}
+			return
+		}
+		if m.shadow(ok, key) {
+			handler.HandleSession(s)
+			return
+		}
+		if !ok {
+			s.Close()
+			return
+		}
+		handler.HandleSession(s)
+	})
+}
+
+// GinConcurrencyMiddleware 返回使用自适应并发限制器 l 减载的 gin 中间件，
+// 响应状态码为 5xx 时视为过载，其他请求按照延迟调整限制。
+// 被减载的请求交给 RejectHandler 处理，结果为 nil。
+func GinConcurrencyMiddleware(l *ConcurrencyLimiter, opts ...option.Option[Middleware]) gin.HandlerFunc {
+	m := newMiddleware(opts...)
+	return func(ctx *gin.Context) {
+		token, ok := l.Acquire()
+		if !ok {
+			if m.shadow(false, ctx.FullPath()) {
+				ctx.Next()
+				return
+			}
+			m.onReject(ctx, nil)
+			ctx.Abort()
+			return
+		}
+		// 处理函数 panic 时只释放许可
+		defer token.OnIgnore()
+		ctx.Next()
+		if ctx.Writer.Status() >= http.StatusInternalServerError {
+			token.OnDropped()
+			return
+		}
+		token.OnSuccess()
+	}
+}
+
+// NetxConcurrencyHandler 返回使用自适应并发限制器 l 处理消息的 netx.Handler，
+// 循环接收 T 类型的消息，获取许可后在新的 goroutine 中调用 handle，接收出错时（例如会话关闭）
+// 等待正在处理的消息结束后退出。同一个会话的消息并发处理，不保证处理顺序，
+// 延迟从收到消息开始计算，不包括等待前面的消息处理的时间。
+// 被减载的消息交给 onReject 处理，onReject 为 nil 时直接丢弃。
+// handle 返回的错误只用于调整并发限制，context.DeadlineExceeded 视为过载。
+func NetxConcurrencyHandler[T any](l *ConcurrencyLimiter, handle func(s *netx.Session, msg T) error,
+	onReject func(s *netx.Session, msg T)) netx.Handler {
+	return netx.HandlerFunc(func(s *netx.Session) {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for {
+			var msg T
+			if err := s.Receive(&msg); err != nil {
+				return
+			}
+			token, ok := l.Acquire()
+			if !ok {
+				if onReject != nil {
+					onReject(s, msg)
+				}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token.done(handle(s, msg))
+			}()
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoConfig 配置源中没有配置，Watcher 保持当前的参数
+	ErrNoConfig = errors.New("limiter: no limit config")
+	// ErrInvalidConfig 配置的桶容量或者速率不大于 0
+	ErrInvalidConfig = errors.New("limiter: invalid limit config")
+)
+
+// Reconfigurable 可以在运行时修改参数的令牌桶限流器，修改后已有的令牌桶平滑地调整，不会被重置
+type Reconfigurable interface {
+	// SetLimit 修改桶容量和每秒产生的令牌数，参数不大于 0 时 panic
+	SetLimit(cap, rate int64)
+}
+
+// LimitConfig 令牌桶限流器的参数
+type LimitConfig struct {
+	// Capacity 桶容量
+	Capacity int64 `json:"capacity"`
+	// Rate 每秒产生令牌数量
+	Rate int64 `json:"rate"`
+}
+
+func (c LimitConfig) validate() error {
+	if c.Capacity <= 0 || c.Rate <= 0 {
+		return fmt.Errorf("%w: capacity %d, rate %d", ErrInvalidConfig, c.Capacity, c.Rate)
+	}
+	return nil
+}
+
+// ConfigSource 限流参数的配置源
+type ConfigSource interface {
+	// Load 读取当前的限流参数，没有配置时返回 ErrNoConfig
+	Load(ctx context.Context) (LimitConfig, error)
+}
+
+// ConfigSourceFunc 函数形式的 ConfigSource
+type ConfigSourceFunc func(ctx context.Context) (LimitConfig, error)
+
+func (f ConfigSourceFunc) Load(ctx context.Context) (LimitConfig, error) {
+	return f(ctx)
+}
+
+// FileConfigSource 从 JSON 文件读取限流参数，例如 {"capacity": 100, "rate": 50}
+type FileConfigSource struct {
+	path string
+}
+
+func NewFileConfigSource(path string) *FileConfigSource {
+	return &FileConfigSource{path: path}
+}
+
+// Load 读取文件中的限流参数，文件不存在时返回 ErrNoConfig
+func (f *FileConfigSource) Load(ctx context.Context) (LimitConfig, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return LimitConfig{}, ErrNoConfig
+	}
+	if err != nil {
+		return LimitConfig{}, err
+	}
+	var cfg LimitConfig
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// RedisConfigSource 从 redis 字符串 key 读取 JSON 编码的限流参数，便于所有节点共享同一份配置
+type RedisConfigSource struct {
+	cli redis.Cmdable
+	key string
+}
+
+func NewRedisConfigSource(cli redis.Cmdable, key string) *RedisConfigSource {
+	return &RedisConfigSource{cli: cli, key: key}
+}
+
+// Load 读取 key 中的限流参数，key 不存在时返回 ErrNoConfig
+func (r *RedisConfigSource) Load(ctx context.Context) (LimitConfig, error) {
+	data, err := r.cli.Get(ctx, r.key).Bytes()
+	if err == redis.Nil {
+		return LimitConfig{}, ErrNoConfig
+	}
+	if err != nil {
+		return LimitConfig{}, err
+	}
+	var cfg LimitConfig
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// Watcher 定期从配置源读取限流参数，参数变化时修改所有的限流器，用于不重启服务调整限流
+type Watcher struct {
+	source   ConfigSource
+	targets  []Reconfigurable
+	interval time.Duration
+	logger   Logger
+
+	mu      sync.Mutex
+	current LimitConfig
+}
+
+// NewWatcher 创建 Watcher 实例，默认每 10 秒读取一次配置源
+func NewWatcher(source ConfigSource, targets []Reconfigurable, opts ...option.Option[Watcher]) *Watcher {
+	w := &Watcher{
+		source:   source,
+		targets:  targets,
+		interval: 10 * time.Second,
+		logger:   log.Default(),
+	}
+	option.Options[Watcher](opts).Apply(w)
+	return w
+}
+
+// WithWatchInterval 设置读取配置源的间隔
+func WithWatchInterval(interval time.Duration) option.Option[Watcher] {
+	return func(t *Watcher) {
+		t.interval = interval
+	}
+}
+
+// WithWatchLogger 设置记录配置错误的日志
+func WithWatchLogger(logger Logger) option.Option[Watcher] {
+	return func(t *Watcher) {
+		t.logger = logger
+	}
+}
+
+// Config 返回最近一次应用的限流参数，还没有应用过时为零值
+func (w *Watcher) Config() LimitConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload 读取一次配置源，参数变化时修改所有的限流器。
+// 配置无效时保持当前的参数并返回错误。
+func (w *Watcher) Reload(ctx context.Context) error {
+	cfg, err := w.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err = cfg.validate(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cfg == w.current {
+		return nil
+	}
+	for _, t := range w.targets {
+		t.SetLimit(cfg.Capacity, cfg.Rate)
+	}
+	w.current = cfg
+	w.logger.Printf("limiter: limit changed to capacity %d, rate %d", cfg.Capacity, cfg.Rate)
+	return nil
+}
+
+// Run 立即读取一次配置源，之后定期读取，阻塞直到 ctx 结束。
+// 读取出错时记录日志并保持当前的参数，没有配置时不记录。
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Reload(ctx); err != nil && !errors.Is(err, ErrNoConfig) && ctx.Err() == nil {
+			w.logger.Printf("limiter: reload limit config: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBucket_SetLimit(t *testing.T) {
+	clock := newMockClock()
+	b := NewBucket(10, 10, WithClock(clock))
+	ok, _ := b.AllowN(context.Background(), "", 8)
+	require.True(t, ok)
+
+	// 修改之前的时间按照旧的速率补充令牌，已有的令牌不会被重置
+	clock.Advance(100 * time.Millisecond)
+	b.SetLimit(100, 100)
+	res, err := b.TakeN(context.Background(), "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 100, res.Limit)
+	assert.Equal(t, 3, res.Remaining)
+
+	clock.Advance(100 * time.Millisecond)
+	res, err = b.TakeN(context.Background(), "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 13, res.Remaining)
+
+	// 调小容量时丢弃超过容量的令牌
+	b.SetLimit(5, 1)
+	res, err = b.TakeN(context.Background(), "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 5, res.Remaining)
+
+	assert.Panics(t, func() {
+		b.SetLimit(0, 1)
+	})
+}
+
+func TestIPTokenBucketLimiter_SetLimit(t *testing.T) {
+	ctx := context.Background()
+	l := NewIPTokenBucketLimiter(10, WithTokenBucket(2, 1))
+	assertAllowed(t, l, "ip", 2)
+
+	// 已有的令牌桶使用新的容量，但是已经用掉的令牌不会恢复
+	l.SetLimit(3, 1)
+	res, err := l.TakeN(ctx, "ip", 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assertAllowed(t, l, "other", 3)
+}
+
+func TestIPTokenBucketLimiter_Build(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewIPTokenBucketLimiter(10, WithTokenBucket(3, 1))
+	r := gin.New()
+	handler := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	r.GET("/a", l.Build(1, 1)(handler))
+	r.GET("/b", l.Build(2, 1)(handler))
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// 每次 Build 的参数只作用于自己的中间件，不修改 l 的参数
+	l.SetLimit(5, 1)
+	assert.Equal(t, http.StatusOK, serve("/a"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/a"))
+	assert.Equal(t, http.StatusOK, serve("/b"))
+	assert.Equal(t, http.StatusOK, serve("/b"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/b"))
+	assertAllowed(t, l, "192.0.2.1", 5)
+}
+
+func TestGinBucketLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := newMockClock()
+	bucket := NewBucket(1, 1, WithClock(clock))
+	r := gin.New()
+	r.GET("/", GinBucketLimiter(bucket)(func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}))
+	serve := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusTooManyRequests, serve())
+	// 运行时调整参数，令牌按照新的速率补充
+	bucket.SetLimit(100, 1000)
+	clock.Advance(10 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serve())
+}
+
+func TestRedisTokenBucketLimiter_SetLimit(t *testing.T) {
+	s := miniredis.RunT(t)
+	l := NewRedisTokenBucketLimiter(redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		WithRedisBucketCapacity(5), WithRedisBucketRate(1))
+	ctx := context.Background()
+	ok, err := l.AllowN(ctx, "key", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	l.SetLimit(2, 1)
+	res, err := l.TakeN(ctx, "key", 1)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+}
+
+func TestWatcher(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "limit.json")
+	bucket := NewBucket(10, 10)
+	ipLimiter := NewIPTokenBucketLimiter(10)
+	var logger logs
+	w := NewWatcher(NewFileConfigSource(path), []Reconfigurable{bucket, ipLimiter}, WithWatchLogger(&logger))
+
+	assert.ErrorIs(t, w.Reload(ctx), ErrNoConfig)
+	assert.Equal(t, LimitConfig{}, w.Config())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"capacity": 3, "rate": 1}`), 0o644))
+	require.NoError(t, w.Reload(ctx))
+	assert.Equal(t, LimitConfig{Capacity: 3, Rate: 1}, w.Config())
+	assertAllowed(t, bucket, "", 3)
+	assertAllowed(t, ipLimiter, "ip", 3)
+	// 参数没有变化时不重复修改
+	require.NoError(t, w.Reload(ctx))
+	assert.Len(t, logger, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"capacity": 0, "rate": 1}`), 0o644))
+	assert.ErrorIs(t, w.Reload(ctx), ErrInvalidConfig)
+	require.NoError(t, os.WriteFile(path, []byte(`{`), 0o644))
+	assert.Error(t, w.Reload(ctx))
+	assert.Equal(t, LimitConfig{Capacity: 3, Rate: 1}, w.Config())
+}
+
+func TestWatcher_Run(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	bucket := NewBucket(10, 10)
+	var logger logs
+	w := NewWatcher(NewRedisConfigSource(rdb, "limit"), []Reconfigurable{bucket},
+		WithWatchInterval(10*time.Millisecond), WithWatchLogger(&logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	require.NoError(t, rdb.Set(ctx, "limit", `{"capacity": 2, "rate": 1}`, 0).Err())
+	require.Eventually(t, func() bool {
+		return w.Config() == LimitConfig{Capacity: 2, Rate: 1}
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assertAllowed(t, bucket, "", 2)
+	assert.Equal(t, logs{"limiter: limit changed to capacity 2, rate 1"}, logger)
+}
+
+// assertAllowed 断言 key 恰好允许 n 次请求通过
+func assertAllowed(t *testing.T, l Limiter, key string, n int) {
+	for i := 0; i < n; i++ {
+		ok, err := l.Allow(context.Background(), key)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	ok, err := l.Allow(context.Background(), key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,228 @@
+package limiter
+
+import (
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+// registryMinShardKeys 限制 key 的数量时每个分片至少保存的 key 数量，
+// maxKeys 较小时减少分片数量，避免某个分片过早装满
+const registryMinShardKeys = 64
+
+// Registry 按照 key 保存本地限流器，例如每个 IP 一个令牌桶。
+// key 分布在多个分片中以降低锁竞争，空闲超过 idleTTL 的 key 在所在分片插入新 key 时被清理。
+// 分片中的 key 达到上限并且没有空闲的 key 时，不淘汰正在使用的 key，
+// 因此攻击者无法通过大量伪造的 key 把已有的限流器挤出去来重置限流。
+// 此时新的 key 保存在分片的溢出区中，每个 key 仍然有自己的限流器，溢出区同样只清理空闲的 key。
+// 溢出区也满时，之前没有出现过的 key 共用分片的兜底限流器，不会得到新的限流器，
+// 已有的 key（包括攻击者自己的）始终使用原来的限流器。分片有空位时溢出的 key 连同它的限流器移回分片。
+// idleTTL 应该不小于限流器恢复满额需要的时间，这样清理空闲的 key 与重新创建等价。
+type Registry[T any] struct {
+	shards  []*registryShard[T]
+	idleTTL time.Duration
+	// maxKeys 最多保存的 key 数量，不大于 0 时不限制
+	maxKeys int
+	// overflowKeys 溢出区最多保存的 key 数量，不大于 0 时为 maxKeys 的四分之一
+	overflowKeys int
+	clock        Clock
+}
+
+type registryShard[T any] struct {
+	mu      sync.Mutex
+	entries map[string]*registryEntry[T]
+	// overflow 分片已满时新的 key 的限流器
+	overflow     map[string]*registryEntry[T]
+	overflowKeys int
+	// fallback 溢出区也满时新的 key 共用的兜底限流器
+	fallback *T
+	maxKeys  int
+	// sweepAt entries 达到该大小时清理空闲的 key
+	sweepAt int
+	// nextSweep 分片已满时下一次允许清理的时间，避免大量新的 key 导致每次都遍历分片
+	nextSweep time.Time
+}
+
+type registryEntry[T any] struct {
+	value    T
+	lastUsed time.Time
+}
+
+// NewRegistry 创建 Registry 实例，默认 32 个分片，key 空闲 10 分钟后清理，不限制 key 的数量。
+// 限制 key 的数量时每个分片至少保存 64 个 key，maxKeys 较小时分片数量相应减少。
+func NewRegistry[T any](opts ...option.Option[Registry[T]]) *Registry[T] {
+	r := &Registry[T]{
+		idleTTL: 10 * time.Minute,
+		clock:   realClock{},
+	}
+	r.shards = make([]*registryShard[T], 32)
+	option.Options[Registry[T]](opts).Apply(r)
+	maxKeys, overflowKeys := 0, 0
+	if r.maxKeys > 0 {
+		if n := r.maxKeys / registryMinShardKeys; n < len(r.shards) {
+			if n < 1 {
+				n = 1
+			}
+			r.shards = make([]*registryShard[T], n)
+		}
+		if r.overflowKeys <= 0 {
+			r.overflowKeys = (r.maxKeys + 3) / 4
+		}
+		// 向上取整，保证总容量不小于 maxKeys
+		maxKeys = (r.maxKeys + len(r.shards) - 1) / len(r.shards)
+		overflowKeys = (r.overflowKeys + len(r.shards) - 1) / len(r.shards)
+	}
+	for i := range r.shards {
+		r.shards[i] = &registryShard[T]{
+			entries:      make(map[string]*registryEntry[T]),
+			overflow:     make(map[string]*registryEntry[T]),
+			overflowKeys: overflowKeys,
+			maxKeys:      maxKeys,
+			sweepAt:      64,
+		}
+	}
+	return r
+}
+
+// WithRegistryShards 设置分片数量，n 不大于 0 时 panic
+func WithRegistryShards[T any](n int) option.Option[Registry[T]] {
+	return func(t *Registry[T]) {
+		if n <= 0 {
+			panic("shards must be greater than 0")
+		}
+		t.shards = make([]*registryShard[T], n)
+	}
+}
+
+// WithRegistryIdleTTL 设置 key 空闲多久之后被清理
+func WithRegistryIdleTTL[T any](ttl time.Duration) option.Option[Registry[T]] {
+	return func(t *Registry[T]) {
+		t.idleTTL = ttl
+	}
+}
+
+// WithRegistryMaxKeys 设置最多保存的 key 数量，平均分配到每个分片
+func WithRegistryMaxKeys[T any](n int) option.Option[Registry[T]] {
+	return func(t *Registry[T]) {
+		t.maxKeys = n
+	}
+}
+
+// WithRegistryOverflowKeys 设置分片已满时溢出区最多保存的 key 数量，平均分配到每个分片，
+// 默认为 maxKeys 的四分之一，没有限制 key 的数量时不生效
+func WithRegistryOverflowKeys[T any](n int) option.Option[Registry[T]] {
+	return func(t *Registry[T]) {
+		t.overflowKeys = n
+	}
+}
+
+// WithRegistryClock 设置时钟
+func WithRegistryClock[T any](clock Clock) option.Option[Registry[T]] {
+	return func(t *Registry[T]) {
+		t.clock = clock
+	}
+}
+
+// Get 返回 key 的限流器，不存在时用 create 创建。
+// 分片已满时返回溢出区中 key 的限流器，同样由 create 创建，溢出区也满时返回分片共用的兜底限流器。
+func (r *Registry[T]) Get(key string, create func() T) T {
+	s := r.shard(key)
+	now := r.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.lastUsed = now
+		return e.value
+	}
+	full := s.maxKeys > 0 && len(s.entries) >= s.maxKeys
+	if len(s.entries) >= s.sweepAt || (full && !now.Before(s.nextSweep)) {
+		s.sweep(now, r.idleTTL)
+	}
+	if s.maxKeys > 0 && len(s.entries) >= s.maxKeys {
+		return s.getOverflow(key, now, create)
+	}
+	e, ok := s.overflow[key]
+	if ok {
+		// 分片有空位时移回分片，保留限流器的状态
+		delete(s.overflow, key)
+		e.lastUsed = now
+	} else {
+		e = &registryEntry[T]{value: create(), lastUsed: now}
+	}
+	s.entries[key] = e
+	return e.value
+}
+
+// getOverflow 返回溢出区中 key 的限流器，不存在时创建。
+// 溢出区已满时不淘汰已有的 key，也不创建新的限流器，返回分片共用的兜底限流器。
+func (s *registryShard[T]) getOverflow(key string, now time.Time, create func() T) T {
+	if e, ok := s.overflow[key]; ok {
+		e.lastUsed = now
+		return e.value
+	}
+	if len(s.overflow) < s.overflowKeys {
+		v := create()
+		s.overflow[key] = &registryEntry[T]{value: v, lastUsed: now}
+		return v
+	}
+	if s.fallback == nil {
+		v := create()
+		s.fallback = &v
+	}
+	return *s.fallback
+}
+
+// Len 返回保存的 key 数量，不包括溢出区中的 key
+func (r *Registry[T]) Len() int {
+	n := 0
+	for _, s := range r.shards {
+		s.mu.Lock()
+		n += len(s.entries)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Range 遍历保存的 key 和限流器，不包括溢出区中的 key，f 返回 false 时停止。
+// 遍历期间持有分片的锁，f 中不能调用 Get。
+func (r *Registry[T]) Range(f func(key string, value T) bool) {
+	for _, s := range r.shards {
+		s.mu.Lock()
+		for key, e := range s.entries {
+			if !f(key, e.value) {
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// shard 返回 key 所在的分片，使用 FNV-1a 哈希
+func (r *Registry[T]) shard(key string) *registryShard[T] {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return r.shards[h%uint32(len(r.shards))]
+}
+
+// sweep 删除空闲超过 ttl 的 key
+func (s *registryShard[T]) sweep(now time.Time, ttl time.Duration) {
+	for key, e := range s.entries {
+		if now.Sub(e.lastUsed) >= ttl {
+			delete(s.entries, key)
+		}
+	}
+	for key, e := range s.overflow {
+		if now.Sub(e.lastUsed) >= ttl {
+			delete(s.overflow, key)
+		}
+	}
+	s.nextSweep = now.Add(time.Second)
+	if s.sweepAt < 2*len(s.entries) {
+		s.sweepAt = 2 * len(s.entries)
+	}
+}
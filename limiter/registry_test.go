@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	clock := newMockClock()
+	r := NewRegistry[*Bucket](WithRegistryShards[*Bucket](1), WithRegistryMaxKeys[*Bucket](2),
+		WithRegistryOverflowKeys[*Bucket](2), WithRegistryIdleTTL[*Bucket](time.Minute),
+		WithRegistryClock[*Bucket](clock))
+	created := 0
+	create := func() *Bucket {
+		created++
+		return NewBucket(1, 1)
+	}
+
+	a := r.Get("a", create)
+	assert.Same(t, a, r.Get("a", create))
+	b := r.Get("b", create)
+	assert.NotSame(t, a, b)
+	assert.Equal(t, 2, r.Len())
+
+	// 已满时新的 key 保存在溢出区中，各自有自己的限流器，已有的 key 不会被淘汰
+	c := r.Get("c", create)
+	d := r.Get("d", create)
+	assert.NotSame(t, c, d)
+	assert.Same(t, c, r.Get("c", create))
+	assert.Same(t, a, r.Get("a", create))
+	assert.Same(t, b, r.Get("b", create))
+	assert.Equal(t, 4, created)
+	assert.Equal(t, 2, r.Len())
+
+	// 溢出区也满时新的 key 共用兜底限流器，溢出区中的 key 保持原来的限流器
+	e := r.Get("e", create)
+	assert.Same(t, e, r.Get("f", create))
+	assert.Same(t, c, r.Get("c", create))
+	assert.Same(t, d, r.Get("d", create))
+	assert.Equal(t, 5, created)
+
+	// 空闲超过 ttl 的 key 被清理，"a" 一直在使用，分片有空位后 "c" 连同限流器移回分片
+	clock.Advance(40 * time.Second)
+	r.Get("a", create)
+	r.Get("c", create)
+	clock.Advance(40 * time.Second)
+	assert.Same(t, c, r.Get("c", create))
+	assert.Same(t, a, r.Get("a", create))
+	// "d" 空闲被清理，溢出区有空位后新的 key 重新得到自己的限流器
+	g := r.Get("g", create)
+	assert.NotSame(t, e, g)
+	assert.Equal(t, 6, created)
+
+	keys := make(map[string]*Bucket)
+	r.Range(func(key string, value *Bucket) bool {
+		keys[key] = value
+		return true
+	})
+	assert.Equal(t, map[string]*Bucket{"a": a, "c": c}, keys)
+}
+
+func TestNewRegistry_Shards(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxKeys    int
+		wantShards int
+		wantKeys   int
+	}{
+		{name: "unlimited", wantShards: 32},
+		{name: "small", maxKeys: 100, wantShards: 1, wantKeys: 100},
+		{name: "medium", maxKeys: 640, wantShards: 10, wantKeys: 64},
+		{name: "large", maxKeys: 10000, wantShards: 32, wantKeys: 313},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry[int](WithRegistryMaxKeys[int](tc.maxKeys))
+			assert.Len(t, r.shards, tc.wantShards)
+			assert.Equal(t, tc.wantKeys, r.shards[0].maxKeys)
+		})
+	}
+}
+
+func TestRegistry_Sweep(t *testing.T) {
+	clock := newMockClock()
+	r := NewRegistry[int](WithRegistryShards[int](4), WithRegistryIdleTTL[int](time.Minute),
+		WithRegistryClock[int](clock))
+	for i := 0; i < 1000; i++ {
+		r.Get(fmt.Sprint(i), func() int { return i })
+	}
+	assert.Equal(t, 1000, r.Len())
+
+	// 没有上限时插入新 key 也会清理空闲的 key
+	clock.Advance(time.Minute)
+	for i := 0; i < 1000; i++ {
+		r.Get(fmt.Sprint("new", i), func() int { return i })
+	}
+	assert.Less(t, r.Len(), 2000)
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	r := NewRegistry[*Bucket](WithRegistryMaxKeys[*Bucket](100))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				r.Get(fmt.Sprint(i*1000+j), func() *Bucket {
+					return NewBucket(1, 1)
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, r.Len(), 128)
+}
+
+func TestIPTokenBucketLimiter_Flood(t *testing.T) {
+	ctx := context.Background()
+	l := NewIPTokenBucketLimiter(64, WithTokenBucket(2, 1))
+	assertAllowed(t, l, "victim", 2)
+
+	// 大量新的 IP 不会把已有 IP 的令牌桶挤出去重置限流
+	for i := 0; i < 10000; i++ {
+		_, err := l.Allow(ctx, fmt.Sprint("10.0.", i/256, ".", i%256))
+		require.NoError(t, err)
+	}
+	ok, err := l.Allow(ctx, "victim")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.LessOrEqual(t, l.buckets.Len(), 64)
+}
+
+func TestIPTokenBucketLimiter_OverflowFlood(t *testing.T) {
+	ctx := context.Background()
+	l := NewIPTokenBucketLimiter(64, WithTokenBucket(2, 1))
+	for i := 0; i < 64; i++ {
+		_, err := l.Allow(ctx, fmt.Sprint("10.0.0.", i))
+		require.NoError(t, err)
+	}
+
+	// 分片已满，攻击者的令牌桶在溢出区中，用完令牌
+	assertAllowed(t, l, "attacker", 2)
+	// 大量新的 IP 填满溢出区之后共用兜底的令牌桶，不会把攻击者的令牌桶挤出去重置限流
+	for i := 0; i < 20; i++ {
+		_, err := l.Allow(ctx, fmt.Sprint("10.0.1.", i))
+		require.NoError(t, err)
+	}
+	ok, err := l.Allow(ctx, "attacker")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
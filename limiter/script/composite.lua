@@ -0,0 +1,44 @@
+-- KEYS 每条规则的令牌桶，ARGV[1] 当前时间（毫秒），ARGV[2] 请求数 n，之后依次是每条规则的容量和速率
+-- 所有规则都允许时才扣减令牌，任何一条规则拒绝时都不扣减
+-- 返回 {是否允许, 结果对应的规则序号, 剩余令牌数, 重试等待时间, 桶补满的时间}
+-- 拒绝时结果对应触发拒绝的规则，允许时对应剩余令牌最少的规则
+local now = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+
+local buckets = {}
+for i, key in ipairs(KEYS) do
+    local capacity = tonumber(ARGV[1 + 2 * i])
+    local rate = tonumber(ARGV[2 + 2 * i])
+    local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+    local tokens = tonumber(bucket[1])
+    local ts = tonumber(bucket[2])
+    if tokens == nil then
+        tokens = capacity
+        ts = now
+    end
+    if now > ts then
+        tokens = math.min(capacity, tokens + (now - ts) * rate / 1000)
+        ts = now
+    end
+    local reset_after = math.ceil((capacity - tokens) * 1000 / rate)
+    if tokens < n then
+        local retry_after = -1
+        if n <= capacity then
+            retry_after = math.ceil((n - tokens) * 1000 / rate)
+        end
+        return {0, i, math.floor(tokens), retry_after, reset_after}
+    end
+    buckets[i] = {tokens - n, ts, capacity, rate}
+end
+
+local result = 1
+for i, key in ipairs(KEYS) do
+    local bucket = buckets[i]
+    redis.call('HSET', key, 'tokens', tostring(bucket[1]), 'ts', bucket[2])
+    redis.call('PEXPIRE', key, math.ceil(bucket[3] * 1000 / bucket[4]) + 1000)
+    if math.floor(bucket[1]) < math.floor(buckets[result][1]) then
+        result = i
+    end
+end
+local bucket = buckets[result]
+return {1, result, math.floor(bucket[1]), 0, math.ceil((bucket[3] - bucket[1]) * 1000 / bucket[4])}
\ No newline at end of file
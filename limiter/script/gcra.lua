@@ -0,0 +1,31 @@
+-- GCRA 每个 key 只保存理论到达时间 tat，时间单位都是微秒
+-- 返回 {是否允许, 剩余请求数, 重试等待时间, 恢复满额的时间}，永远无法满足时重试等待时间为 -1
+local key = KEYS[1]
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local tolerance = emission * burst
+local increment = emission * n
+
+local tat = tonumber(redis.call('GET', key))
+if tat == nil or tat < now then
+    tat = now
+end
+
+local new_tat = tat + increment
+local diff = now - (new_tat - tolerance)
+if diff < 0 then
+    local remaining = math.floor((now - (tat - tolerance)) / emission)
+    local retry_after = -diff
+    if increment > tolerance then
+        retry_after = -1
+    end
+    return {0, remaining, retry_after, tat - now}
+end
+
+local reset_after = new_tat - now
+if reset_after > 0 then
+    redis.call('SET', key, string.format('%d', new_tat), 'PX', math.ceil(reset_after / 1000))
+end
+return {1, math.floor(diff / emission), 0, reset_after}
\ No newline at end of file
@@ -1,16 +1,35 @@
+-- 返回 {是否允许, 窗口内的请求数, 重试等待时间, 窗口清空的时间}，时间单位都是毫秒
+-- 永远无法满足时重试等待时间为 -1
 local key = KEYS[1]
 local window = tonumber(ARGV[1])
 local threshold = tonumber( ARGV[2])
 local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4] or 1)
 local min = now - window
 
+-- expire_after 返回第 index 个请求移出窗口的时间
+local function expire_after(index)
+    local member = redis.call('ZRANGE', key, index, index, 'WITHSCORES')
+    if member[2] == nil then
+        return 0
+    end
+    return tonumber(member[2]) + window - now
+end
+
 redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
-if cnt >= threshold then
-    return "true"
+if cnt + n > threshold then
+    local retry_after = -1
+    if n <= threshold then
+        retry_after = expire_after(cnt + n - threshold - 1)
+    end
+    return {0, cnt, retry_after, expire_after(-1)}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    -- score 设置成 now，member 加上请求在窗口内的序号，避免同一毫秒的请求被合并成一个成员。
+    -- 同一毫秒内窗口中的请求数只增不减，所以序号不会重复
+    for i = 1, n do
+        redis.call('ZADD', key, now, now .. ':' .. (cnt + i))
+    end
     redis.call('PEXPIRE', key, window)
-    return "false"
+    return {1, cnt + n, 0, window}
 end
\ No newline at end of file
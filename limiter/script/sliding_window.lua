@@ -0,0 +1,52 @@
+-- 滑动窗口计数器保存在 hash 中：win 当前窗口的序号，prev 上一个窗口的请求数，cur 当前窗口的请求数
+-- 用上一个窗口按照剩余时间加权的请求数加上当前窗口的请求数估算滑动窗口内的请求数
+-- 返回 {是否允许, 剩余请求数, 重试等待时间, 恢复满额的时间}，时间单位都是毫秒
+-- n 超过 limit 时永远无法满足，重试等待时间为 -1
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+
+local win = math.floor(now / window)
+local counter = redis.call('HMGET', key, 'win', 'prev', 'cur')
+local stored = tonumber(counter[1])
+local prev = 0
+local cur = 0
+if stored ~= nil and stored >= win then
+    -- 时钟回拨时按照已保存的窗口计算
+    win = stored
+    prev = tonumber(counter[2])
+    cur = tonumber(counter[3])
+elseif stored == win - 1 then
+    prev = tonumber(counter[3])
+end
+local elapsed = math.max(0, now - win * window)
+
+local count = prev * (window - elapsed) / window + cur
+local allowed = 0
+local retry_after = 0
+if count + n <= limit then
+    allowed = 1
+    count = count + n
+    cur = cur + n
+    redis.call('HSET', key, 'win', win, 'prev', prev, 'cur', cur)
+    -- 两个窗口都过去之后 key 与不存在等价，可以过期
+    redis.call('PEXPIRE', key, 2 * window)
+elseif n > limit then
+    retry_after = -1
+elseif cur + n <= limit then
+    -- 等待上一个窗口的权重降低
+    retry_after = math.ceil(window - elapsed - (limit - cur - n) * window / prev)
+else
+    -- 等待当前窗口变成上一个窗口之后权重降低
+    retry_after = math.ceil(2 * window - elapsed - (limit - n) * window / cur)
+end
+
+local reset_after = 0
+if cur > 0 then
+    reset_after = 2 * window - elapsed
+elseif prev > 0 then
+    reset_after = window - elapsed
+end
+return {allowed, math.max(0, math.floor(limit - count)), retry_after, reset_after}
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- 令牌桶保存在 hash 中：tokens 剩余令牌数，ts 上次计算的时间（毫秒）
+-- 返回 {是否允许, 剩余令牌数, 重试等待时间, 桶补满的时间}，时间单位都是毫秒
+-- n 超过桶容量时永远无法满足，重试等待时间为 -1
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil then
+    tokens = capacity
+    ts = now
+end
+
+-- 按照经过的时间补充令牌，时钟回拨时不补充
+if now > ts then
+    tokens = tokens + (now - ts) * rate / 1000
+    ts = now
+end
+-- 容量可能在运行时被调小，超过容量的令牌丢弃
+tokens = math.min(capacity, tokens)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= n then
+    tokens = tokens - n
+    allowed = 1
+elseif n > capacity then
+    retry_after = -1
+else
+    retry_after = math.ceil((n - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', ts)
+-- 桶补满之后 key 与不存在等价，可以过期
+redis.call('PEXPIRE', key, math.ceil(capacity * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry_after, math.ceil((capacity - tokens) * 1000 / rate)}
\ No newline at end of file
@@ -1,22 +1,25 @@
 package limiter
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"github.com/shijting/kit/option"
-	"log"
-	"net/http"
 	"time"
 )
 
-// SlideWindowIPLimiter 滑动窗口IP 限流器
+// SlideWindowIPLimiter 滑动窗口IP 限流器，每个请求在有序集合中保存一个成员，内存占用与请求速率成正比，
+// 速率较高时可以使用近似的 RedisSlidingWindowLimiter
 type SlideWindowIPLimiter struct {
 	prefix   string
 	cli      redis.Cmdable
 	interval time.Duration
 	rate     int
+	// policy 非空时 Build 返回的中间件按照策略处理 redis 错误
+	policy       *FailurePolicy
+	failoverOpts []option.Option[FailoverLimiter]
 }
 
 //go:embed script/slide_window.lua
@@ -53,25 +56,64 @@ func WithPrefix(prefix string) option.Option[SlideWindowIPLimiter] {
 	}
 }
 
-func (b *SlideWindowIPLimiter) Build() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		limited, err := b.accept(ctx)
-		if err != nil {
-			log.Println(err)
-			ctx.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
-		if limited {
-			log.Println(err)
-			ctx.AbortWithStatus(http.StatusTooManyRequests)
-			return
-		}
-		ctx.Next()
+// WithFailurePolicy 设置 Build 返回的中间件在 redis 出错时的处理策略，默认返回 500
+func WithFailurePolicy(policy FailurePolicy, opts ...option.Option[FailoverLimiter]) option.Option[SlideWindowIPLimiter] {
+	return func(t *SlideWindowIPLimiter) {
+		t.policy = &policy
+		t.failoverOpts = opts
 	}
 }
 
-func (b *SlideWindowIPLimiter) accept(ctx *gin.Context) (bool, error) {
-	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.cli.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+// Build 返回按照客户端 IP 限流的 gin 中间件
+func (b *SlideWindowIPLimiter) Build(opts ...option.Option[Middleware]) gin.HandlerFunc {
+	if b.policy != nil {
+		return GinMiddleware(NewFailoverLimiter(b, *b.policy, b.failoverOpts...), GinKeyByIP(), opts...)
+	}
+	return GinMiddleware(b, GinKeyByIP(), opts...)
+}
+
+// Local 返回相同参数的本地限流器，用 GCRA 近似滑动窗口
+func (b *SlideWindowIPLimiter) Local() ResultLimiter {
+	return NewGCRALimiter(b.rate, b.interval, b.rate)
+}
+
+func (b *SlideWindowIPLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return b.AllowN(ctx, key, 1)
+}
+
+func (b *SlideWindowIPLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := b.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+// Wait 阻塞直到允许通过或者 ctx 结束，被拒绝时等待到窗口中最早的请求移出窗口
+func (b *SlideWindowIPLimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, realClock{}, func(ctx context.Context) (*Result, error) {
+		return b.TakeN(ctx, key, 1)
+	})
+}
+
+func (b *SlideWindowIPLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	res, err := b.cli.Eval(ctx, luaScript, []string{fmt.Sprintf("%s:%s", b.prefix, key)},
+		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Allowed:    res[0] == 1,
+		Limit:      b.rate,
+		Remaining:  b.rate - int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if res[2] < 0 {
+		result.RetryAfter = -1
+	}
+	return result, nil
 }
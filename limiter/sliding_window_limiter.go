@@ -0,0 +1,234 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+//go:embed script/sliding_window.lua
+var luaSlidingWindow string
+
+var (
+	_ ResultLimiter = (*SlidingWindowLimiter)(nil)
+	_ ResultLimiter = (*RedisSlidingWindowLimiter)(nil)
+	_ Localizer     = (*RedisSlidingWindowLimiter)(nil)
+)
+
+// SlidingWindowLimiter 基于滑动窗口计数器的本地限流器。
+// 每个 key 只保存当前和上一个固定窗口的请求数，用上一个窗口按照剩余时间加权的请求数
+// 加上当前窗口的请求数估算滑动窗口内的请求数，内存占用与请求速率无关。
+type SlidingWindowLimiter struct {
+	limit    int
+	window   time.Duration
+	clock    Clock
+	mu       sync.Mutex
+	counters map[string]*windowCounter
+	// sweepAt counters 达到该大小时清理两个窗口都已经过去的 key
+	sweepAt int
+}
+
+// windowCounter 一个 key 的窗口计数
+type windowCounter struct {
+	// index 当前窗口的序号
+	index int64
+	prev  int
+	cur   int
+}
+
+// NewSlidingWindowLimiter 创建 SlidingWindowLimiter 实例。
+// limit: 每个 window 允许的请求数。
+func NewSlidingWindowLimiter(limit int, window time.Duration, opts ...option.Option[SlidingWindowLimiter]) *SlidingWindowLimiter {
+	if limit <= 0 || window <= 0 {
+		panic("limit and window must be greater than 0")
+	}
+	limiter := &SlidingWindowLimiter{
+		limit:    limit,
+		window:   window,
+		clock:    realClock{},
+		counters: make(map[string]*windowCounter),
+		sweepAt:  1024,
+	}
+	option.Options[SlidingWindowLimiter](opts).Apply(limiter)
+	return limiter
+}
+
+// WithSlidingWindowClock 设置时钟
+func WithSlidingWindowClock(clock Clock) option.Option[SlidingWindowLimiter] {
+	return func(t *SlidingWindowLimiter) {
+		t.clock = clock
+	}
+}
+
+func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+func (s *SlidingWindowLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := s.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+func (s *SlidingWindowLimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, s.clock, func(ctx context.Context) (*Result, error) {
+		return s.TakeN(ctx, key, 1)
+	})
+}
+
+func (s *SlidingWindowLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().UnixNano()
+	index := now / int64(s.window)
+	elapsed := time.Duration(now - index*int64(s.window))
+	c, ok := s.counters[key]
+	if !ok {
+		c = &windowCounter{index: index}
+		s.counters[key] = c
+	}
+	switch {
+	case c.index == index-1:
+		c.prev, c.cur = c.cur, 0
+		c.index = index
+	case c.index < index-1:
+		c.prev, c.cur = 0, 0
+		c.index = index
+	}
+
+	res := slidingWindow(s.limit, s.window, elapsed, c.prev, c.cur, n)
+	if res.Allowed {
+		c.cur += n
+	}
+	if len(s.counters) >= s.sweepAt {
+		s.sweep(index)
+	}
+	return res, nil
+}
+
+// sweep 删除两个窗口都已经过去的 key，与不存在的 key 等价
+func (s *SlidingWindowLimiter) sweep(index int64) {
+	for key, c := range s.counters {
+		if c.index < index-1 || (c.index == index-1 && c.cur == 0) {
+			delete(s.counters, key)
+		}
+	}
+	if s.sweepAt < 2*len(s.counters) {
+		s.sweepAt = 2 * len(s.counters)
+	}
+}
+
+// slidingWindow 根据上一个窗口和当前窗口的请求数判断 n 个请求能否通过，
+// elapsed 为当前窗口已经经过的时间，返回的结果中 Remaining 和 ResetAfter 已经计入通过的请求
+func slidingWindow(limit int, window, elapsed time.Duration, prev, cur, n int) *Result {
+	count := float64(prev)*float64(window-elapsed)/float64(window) + float64(cur)
+	res := &Result{Limit: limit}
+	switch {
+	case count+float64(n) <= float64(limit):
+		res.Allowed = true
+		count += float64(n)
+		cur += n
+	case n > limit:
+		res.RetryAfter = -1
+	case cur+n <= limit:
+		// 等待上一个窗口的权重降低
+		res.RetryAfter = window - elapsed - time.Duration(float64(limit-cur-n)/float64(prev)*float64(window))
+	default:
+		// 等待当前窗口变成上一个窗口之后权重降低
+		res.RetryAfter = 2*window - elapsed - time.Duration(float64(limit-n)/float64(cur)*float64(window))
+	}
+	if count < float64(limit) {
+		res.Remaining = int(float64(limit) - count)
+	}
+	switch {
+	case cur > 0:
+		res.ResetAfter = 2*window - elapsed
+	case prev > 0:
+		res.ResetAfter = window - elapsed
+	}
+	return res
+}
+
+// RedisSlidingWindowLimiter 基于 redis 的分布式滑动窗口计数器限流器，算法与 SlidingWindowLimiter 相同。
+// 相比 SlideWindowIPLimiter 每个请求一个有序集合成员，每个 key 只保存一个 hash，内存占用与请求速率无关。
+// 各节点的时钟偏差会影响限流的精度。
+type RedisSlidingWindowLimiter struct {
+	prefix string
+	cli    redis.Cmdable
+	limit  int
+	window time.Duration
+}
+
+// NewRedisSlidingWindowLimiter 创建 RedisSlidingWindowLimiter 实例。
+// limit: 每个 window 允许的请求数，window 的精度为毫秒。
+func NewRedisSlidingWindowLimiter(cli redis.Cmdable, limit int, window time.Duration,
+	opts ...option.Option[RedisSlidingWindowLimiter]) *RedisSlidingWindowLimiter {
+	if limit <= 0 || window < time.Millisecond {
+		panic("limit must be greater than 0 and window must be at least 1ms")
+	}
+	limiter := &RedisSlidingWindowLimiter{
+		cli:    cli,
+		prefix: "sliding-window-limiter",
+		limit:  limit,
+		window: window,
+	}
+
+	option.Options[RedisSlidingWindowLimiter](opts).Apply(limiter)
+
+	return limiter
+}
+
+func WithRedisSlidingWindowPrefix(prefix string) option.Option[RedisSlidingWindowLimiter] {
+	return func(t *RedisSlidingWindowLimiter) {
+		t.prefix = prefix
+	}
+}
+
+// Local 返回相同参数的本地限流器
+func (s *RedisSlidingWindowLimiter) Local() ResultLimiter {
+	return NewSlidingWindowLimiter(s.limit, s.window)
+}
+
+func (s *RedisSlidingWindowLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+func (s *RedisSlidingWindowLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := s.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+func (s *RedisSlidingWindowLimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, realClock{}, func(ctx context.Context) (*Result, error) {
+		return s.TakeN(ctx, key, 1)
+	})
+}
+
+func (s *RedisSlidingWindowLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	res, err := s.cli.Eval(ctx, luaSlidingWindow, []string{fmt.Sprintf("%s:%s", s.prefix, key)},
+		s.window.Milliseconds(), s.limit, time.Now().UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Allowed:    res[0] == 1,
+		Limit:      s.limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}
+	if res[2] < 0 {
+		result.RetryAfter = -1
+	}
+	return result, nil
+}
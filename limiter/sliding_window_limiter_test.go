@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	clock := newMockClock()
+	l := NewSlidingWindowLimiter(10, time.Second, WithSlidingWindowClock(clock))
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		advance time.Duration
+		n       int
+		want    Result
+	}{
+		{
+			name: "first",
+			n:    6,
+			want: Result{Allowed: true, Limit: 10, Remaining: 4, ResetAfter: 2 * time.Second},
+		},
+		{
+			name: "exceed current window",
+			n:    5,
+			want: Result{Limit: 10, Remaining: 4, RetryAfter: 1166666667, ResetAfter: 2 * time.Second},
+		},
+		{
+			name:    "take remaining",
+			advance: 500 * time.Millisecond,
+			n:       4,
+			want:    Result{Allowed: true, Limit: 10, Remaining: 0, ResetAfter: 1500 * time.Millisecond},
+		},
+		{
+			name:    "weighted previous window",
+			advance: 700 * time.Millisecond,
+			n:       3,
+			want:    Result{Limit: 10, Remaining: 2, RetryAfter: 100 * time.Millisecond, ResetAfter: 800 * time.Millisecond},
+		},
+		{
+			name:    "previous window decayed",
+			advance: 100 * time.Millisecond,
+			n:       3,
+			want:    Result{Allowed: true, Limit: 10, Remaining: 0, ResetAfter: 1700 * time.Millisecond},
+		},
+		{
+			name: "exceed limit",
+			n:    11,
+			want: Result{Limit: 10, Remaining: 0, RetryAfter: -1, ResetAfter: 1700 * time.Millisecond},
+		},
+		{
+			name:    "both windows passed",
+			advance: 3 * time.Second,
+			n:       10,
+			want:    Result{Allowed: true, Limit: 10, Remaining: 0, ResetAfter: 1700 * time.Millisecond},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock.Advance(tc.advance)
+			res, err := l.TakeN(ctx, "key", tc.n)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, *res)
+		})
+	}
+
+	// 两个窗口都过去的 key 会被清理
+	l.sweepAt = 1
+	clock.Advance(2 * time.Second)
+	_, err := l.TakeN(ctx, "other", 1)
+	require.NoError(t, err)
+	assert.Len(t, l.counters, 1)
+}
+
+func TestRedisSlidingWindowLimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	l := NewRedisSlidingWindowLimiter(rdb, 3, time.Second, WithRedisSlidingWindowPrefix("sw"))
+	ctx := context.Background()
+
+	res, err := l.TakeN(ctx, "key", 3)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Greater(t, res.ResetAfter, time.Second)
+	assert.LessOrEqual(t, res.ResetAfter, 2*time.Second)
+	assert.True(t, s.Exists("sw:key"))
+
+	// 同一毫秒的并发请求都被计数
+	for i := 0; i < 3; i++ {
+		ok, err := l.Allow(ctx, "key")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+	res, err = l.TakeN(ctx, "key", 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Greater(t, res.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, res.RetryAfter, 2*time.Second)
+
+	res, err = l.TakeN(ctx, "key", 4)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), res.RetryAfter)
+
+	// 两个窗口都过去之后 key 过期
+	s.FastForward(2 * time.Second)
+	assert.False(t, s.Exists("sw:key"))
+
+	local, ok := l.Local().(*SlidingWindowLimiter)
+	require.True(t, ok)
+	assert.Equal(t, 3, local.limit)
+	assert.Equal(t, time.Second, local.window)
+}
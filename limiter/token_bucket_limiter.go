@@ -0,0 +1,127 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+var (
+	//go:embed script/token_bucket.lua
+	luaTokenBucket string
+	// ErrExceedCapacity 请求的令牌数超过桶容量，永远无法满足
+	ErrExceedCapacity = errors.New("limiter: n exceeds bucket capacity")
+)
+
+// RedisTokenBucketLimiter 基于 redis 的分布式令牌桶限流器，每个 key 一个令牌桶，
+// 桶的令牌数和上次补充时间保存在 redis hash 中，由 lua 脚本原子地补充和扣减。
+// 各节点的时钟偏差会影响令牌补充的精度。
+type RedisTokenBucketLimiter struct {
+	prefix string
+	cli    redis.Cmdable
+	// capacity 桶容量，也是允许的最大突发请求数
+	capacity int
+	// rate 每秒产生令牌数量
+	rate int
+	mu   sync.RWMutex
+}
+
+func NewRedisTokenBucketLimiter(cli redis.Cmdable, opts ...option.Option[RedisTokenBucketLimiter]) *RedisTokenBucketLimiter {
+	limiter := &RedisTokenBucketLimiter{
+		cli:      cli,
+		prefix:   "token-bucket-limiter",
+		capacity: 200,
+		rate:     200,
+	}
+
+	option.Options[RedisTokenBucketLimiter](opts).Apply(limiter)
+
+	return limiter
+}
+
+func WithRedisBucketCapacity(capacity int) option.Option[RedisTokenBucketLimiter] {
+	return func(t *RedisTokenBucketLimiter) {
+		t.capacity = capacity
+	}
+}
+
+func WithRedisBucketRate(rate int) option.Option[RedisTokenBucketLimiter] {
+	return func(t *RedisTokenBucketLimiter) {
+		t.rate = rate
+	}
+}
+
+func WithRedisBucketPrefix(prefix string) option.Option[RedisTokenBucketLimiter] {
+	return func(t *RedisTokenBucketLimiter) {
+		t.prefix = prefix
+	}
+}
+
+// SetLimit 修改桶容量和每秒产生的令牌数，redis 中已有的令牌桶在下次使用时按照新的参数计算，
+// 超过新容量的令牌被丢弃，不会重置。只修改当前节点，各节点需要分别修改，例如使用 Watcher。
+func (b *RedisTokenBucketLimiter) SetLimit(cap, rate int64) {
+	if cap <= 0 || rate <= 0 {
+		panic("cap and rate must be greater than 0")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.capacity = int(cap)
+	b.rate = int(rate)
+}
+
+// limit 返回桶容量和每秒产生的令牌数
+func (b *RedisTokenBucketLimiter) limit() (int, int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.capacity, b.rate
+}
+
+// Local 返回相同参数的本地限流器，最多保存 localMaxKeys 个 key 的令牌桶
+func (b *RedisTokenBucketLimiter) Local() ResultLimiter {
+	capacity, rate := b.limit()
+	return NewIPTokenBucketLimiter(localMaxKeys, WithTokenBucket(int64(capacity), int64(rate)))
+}
+
+func (b *RedisTokenBucketLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	return b.AllowN(ctx, key, 1)
+}
+
+func (b *RedisTokenBucketLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	res, err := b.TakeN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+// Wait 阻塞直到拿到令牌或者 ctx 结束，令牌不足时按照脚本返回的时间等待后重试
+func (b *RedisTokenBucketLimiter) Wait(ctx context.Context, key string) error {
+	return waitResult(ctx, realClock{}, func(ctx context.Context) (*Result, error) {
+		return b.TakeN(ctx, key, 1)
+	})
+}
+
+func (b *RedisTokenBucketLimiter) TakeN(ctx context.Context, key string, n int) (*Result, error) {
+	capacity, rate := b.limit()
+	res, err := b.cli.Eval(ctx, luaTokenBucket, []string{fmt.Sprintf("%s:%s", b.prefix, key)},
+		capacity, rate, time.Now().UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Allowed:    res[0] == 1,
+		Limit:      capacity,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}
+	if res[2] < 0 {
+		result.RetryAfter = -1
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisTokenBucketLimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	l := NewRedisTokenBucketLimiter(rdb, WithRedisBucketPrefix("tb"),
+		WithRedisBucketCapacity(2), WithRedisBucketRate(20))
+
+	ok, err := l.AllowN(ctx, "key", 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, s.Exists("tb:key"))
+	ok, err = l.Allow(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 其他 key 的桶互不影响
+	ok, err = l.Allow(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 每 50ms 产生一个令牌，Wait 按照返回的时间等待
+	start := time.Now()
+	require.NoError(t, l.Wait(ctx, "key"))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	// 超过桶容量的请求永远无法满足
+	ok, err = l.AllowN(ctx, "key", 3)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.ErrorIs(t, NewRedisTokenBucketLimiter(rdb, WithRedisBucketCapacity(0)).Wait(ctx, "key"), ErrExceedCapacity)
+
+	// 桶补满所需的时间之后 key 过期
+	s.FastForward(2 * time.Second)
+	assert.False(t, s.Exists("tb:key"))
+}
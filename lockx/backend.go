@@ -0,0 +1,98 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+var (
+	_ Backend = (*RedisBackend)(nil)
+	_ Backend = (*MemoryBackend)(nil)
+	// ErrBackendNotSupported 当前后端不支持该类型的锁
+	ErrBackendNotSupported = errors.New("backend not supported")
+)
+
+// Backend 锁的存储后端
+type Backend interface {
+	// Lock key 未被持有或者被 value 持有时获取锁并设置过期时间，第一次获取时记录获取时间。
+	// 获取成功时返回 fencing token，value 重复获取时返回相同的 token，未获取到时返回 0。
+	Lock(ctx context.Context, key, value string, expiration time.Duration) (int64, error)
+	// TryLock 只在 key 未被持有时获取锁，value 已经持有时同样失败。
+	// 获取成功时返回 fencing token，未获取到时返回 0。
+	TryLock(ctx context.Context, key, value string, expiration time.Duration) (int64, error)
+	// Unlock key 被 value 持有时释放锁，返回是否释放成功
+	Unlock(ctx context.Context, key, value string) (bool, error)
+	// Refresh key 被 value 持有时刷新过期时间，返回是否刷新成功
+	Refresh(ctx context.Context, key, value string, expiration time.Duration) (bool, error)
+	// Inspect 返回锁的值、剩余过期时间和获取时间（没有记录时为零值），Owner 由调用方解析，
+	// 锁不存在时返回 ErrLockNotFound
+	Inspect(ctx context.Context, key string) (LockInfo, error)
+	// ForceUnlock 不检查持有者直接释放锁，返回锁是否存在
+	ForceUnlock(ctx context.Context, key string) (bool, error)
+}
+
+// RedisBackend 基于 redis lua 脚本的后端
+type RedisBackend struct {
+	client redis.Cmdable
+}
+
+// NewRedisBackend 创建 RedisBackend 实例。
+func NewRedisBackend(client redis.Cmdable) *RedisBackend {
+	return &RedisBackend{client: client}
+}
+
+func (b *RedisBackend) Lock(ctx context.Context, key, value string, expiration time.Duration) (int64, error) {
+	return b.client.Eval(ctx, luaLock, []string{key, fenceKey(key), acquiredKey(key)},
+		value, expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+}
+
+func (b *RedisBackend) TryLock(ctx context.Context, key, value string, expiration time.Duration) (int64, error) {
+	return b.client.Eval(ctx, luaTryLock, []string{key, fenceKey(key), acquiredKey(key)},
+		value, expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+}
+
+func (b *RedisBackend) Unlock(ctx context.Context, key, value string) (bool, error) {
+	res, err := b.client.Eval(ctx, luaUnlock, []string{key, acquiredKey(key)}, value).Int64()
+	return res == 1, err
+}
+
+func (b *RedisBackend) Refresh(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	res, err := b.client.Eval(ctx, luaRefresh, []string{key, acquiredKey(key)}, value, expiration.Milliseconds()).Int64()
+	return res == 1, err
+}
+
+func (b *RedisBackend) Inspect(ctx context.Context, key string) (LockInfo, error) {
+	res, err := b.client.Eval(ctx, luaInspect, []string{key, acquiredKey(key)}).Slice()
+	if err == redis.Nil {
+		return LockInfo{}, ErrLockNotFound
+	}
+	if err != nil {
+		return LockInfo{}, err
+	}
+	value, _ := res[0].(string)
+	ttl, _ := res[1].(int64)
+	info := LockInfo{Value: value, TTL: time.Duration(ttl) * time.Millisecond}
+	if len(res) > 2 {
+		if acquired, _ := res[2].(string); acquired != "" {
+			if ms, err := strconv.ParseInt(acquired, 10, 64); err == nil {
+				info.AcquiredAt = time.UnixMilli(ms)
+			}
+		}
+	}
+	return info, nil
+}
+
+func (b *RedisBackend) ForceUnlock(ctx context.Context, key string) (bool, error) {
+	_, _, channel := fairKeys(key)
+	res, err := b.client.Eval(ctx, luaForceUnlock, []string{key, channel, acquiredKey(key)}).Int64()
+	return res == 1, err
+}
+
+// acquiredKey 返回记录 Lock 和 TryLock 获取时间的 key，与锁的过期时间相同。
+// 获取时间不放在锁的值中，保证同一个 Client 的锁的值固定，重入判断与获取时间无关。
+func acquiredKey(key string) string {
+	return key + ":acquired"
+}
@@ -0,0 +1,113 @@
+package lockx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	//go:embed script/lua/fair_lock.lua
+	luaFairLock string
+	//go:embed script/lua/fair_unlock.lua
+	luaFairUnlock string
+	//go:embed script/lua/fair_leave.lua
+	luaFairLeave string
+)
+
+// subscriber 支持发布订阅的 redis 客户端，*redis.Client、*redis.ClusterClient 等都实现了该接口
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// fairKeys 返回公平锁使用的 key：锁、等待队列、等待者心跳和释放通知的频道。
+// redis 集群下需要在 key 中使用 hash tag，例如 {order}，保证这些 key 位于同一个槽。
+func fairKeys(key string) (queue, alive, channel string) {
+	return key + ":queue", key + ":alive", key + ":released"
+}
+
+// FairLock 获取公平锁，等待者按照先来后到的顺序获取锁。
+// 等待者加入 redis 中的等待队列，持有者释放锁时通过发布订阅唤醒等待者，避免轮询。
+// 客户端不支持发布订阅时，退化为按照心跳间隔轮询。
+// FairLock 会一直阻塞到获取锁或者 ctx 结束，不使用重试策略。
+// 等待者需要按照心跳间隔续期在队列中的位置，崩溃的等待者在 3 个心跳间隔后被移出队列。
+// 每次获取都是独立的持有者，同一个 Client 的两次 FairLock 同样互斥，不会被当作重入。
+func (cli *Client) FairLock(ctx context.Context, key string) (*Lock, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if cli.client == nil {
+		return nil, ErrBackendNotSupported
+	}
+	_, _, channel := fairKeys(key)
+	// 锁的值和队列成员使用相同的持有者，与 Semaphore 相同在 value 之后加上随机的后缀
+	holder := cli.value + ":" + uuid.New().String()
+
+	var released <-chan *redis.Message
+	if s, ok := cli.client.(subscriber); ok {
+		pubsub := s.Subscribe(ctx, channel)
+		defer pubsub.Close()
+		// 等待订阅生效，避免错过订阅之前的释放通知
+		if _, err := pubsub.Receive(ctx); err != nil {
+			return nil, err
+		}
+		released = pubsub.Channel()
+	}
+
+	ticker := time.NewTicker(cli.waitHeartbeat)
+	defer ticker.Stop()
+	for {
+		token, err := cli.tryFairLock(ctx, key, holder)
+		if err != nil {
+			cli.leaveFairQueue(key, holder)
+			return nil, err
+		}
+		if token > 0 {
+			lock := cli.newLock(key, holder, cli.expiration, cli.timeout)
+			lock.fair = true
+			lock.token = token
+			return lock, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			cli.leaveFairQueue(key, holder)
+			return nil, ctx.Err()
+		case <-released:
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryFairLock 尝试一次获取公平锁，获取成功时返回 fencing token，未获取到时入队并返回 0。
+// holder 已经持有锁时（例如上一次超时但实际成功）返回当前的 token。
+func (cli *Client) tryFairLock(ctx context.Context, key, holder string) (int64, error) {
+	queue, alive, _ := fairKeys(key)
+	rct, cancel := context.WithTimeout(ctx, cli.timeout)
+	defer cancel()
+	return cli.client.Eval(rct, luaFairLock, []string{key, queue, alive, fenceKey(key)},
+		holder, cli.expiration.Milliseconds(), time.Now().UnixMilli(), (3 * cli.waitHeartbeat).Milliseconds()).Int64()
+}
+
+// leaveFairQueue 放弃等待，离开等待队列
+func (cli *Client) leaveFairQueue(key, holder string) {
+	queue, alive, channel := fairKeys(key)
+	ctx, cancel := context.WithTimeout(context.Background(), cli.timeout)
+	defer cancel()
+	cli.client.Eval(ctx, luaFairLeave, []string{queue, alive, channel}, holder)
+}
+
+// fairUnlock 释放公平锁并通知等待者
+func (l *Lock) fairUnlock(ctx context.Context) error {
+	_, _, channel := fairKeys(l.key)
+	res, err := l.client.Eval(ctx, luaFairUnlock, []string{l.key, channel}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
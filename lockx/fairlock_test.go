@@ -0,0 +1,130 @@
+package lockx
+
+import (
+	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_FairLock(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	// 心跳间隔足够长，只能通过释放通知唤醒等待者
+	holder := NewClient(rdb, WithValue("holder"), WithWaitHeartbeat(time.Minute))
+	lock, err := holder.FairLock(ctx, "key")
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 3; i++ {
+		value := fmt.Sprintf("waiter%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli := NewClient(rdb, WithValue(value), WithWaitHeartbeat(time.Minute))
+			l, err := cli.FairLock(ctx, "key")
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			order = append(order, value)
+			mu.Unlock()
+			assert.NoError(t, l.Unlock(ctx))
+		}()
+		// 保证等待者按顺序入队
+		require.Eventually(t, func() bool {
+			members, _ := s.ZMembers("key:queue")
+			return len(members) == i+1
+		}, time.Second, 5*time.Millisecond)
+	}
+
+	require.NoError(t, lock.Refresh(ctx))
+	require.NoError(t, lock.Unlock(ctx))
+	wg.Wait()
+	assert.Equal(t, []string{"waiter0", "waiter1", "waiter2"}, order)
+	assert.False(t, s.Exists("key"))
+	assert.False(t, s.Exists("key:queue"))
+}
+
+func TestClient_FairLockCancel(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	holder := NewClient(rdb, WithValue("holder"))
+	lock, err := holder.FairLock(context.Background(), "key")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewClient(rdb, WithValue("waiter")).FairLock(ctx, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 放弃等待的等待者离开队列，不影响后来者
+	members, _ := s.ZMembers("key:queue")
+	assert.Empty(t, members)
+	require.NoError(t, lock.Unlock(context.Background()))
+	_, err = NewClient(rdb, WithValue("other")).FairLock(context.Background(), "key")
+	assert.NoError(t, err)
+}
+
+func TestClient_FairLockSameClient(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	cli := NewClient(rdb, WithValue("same"), WithWaitHeartbeat(time.Minute))
+
+	first, err := cli.FairLock(context.Background(), "key")
+	require.NoError(t, err)
+
+	// 同一个 Client 的第二次获取需要等待第一次释放
+	acquired := make(chan *Lock, 1)
+	go func() {
+		l, err := cli.FairLock(context.Background(), "key")
+		if assert.NoError(t, err) {
+			acquired <- l
+		}
+	}()
+	require.Eventually(t, func() bool {
+		members, _ := s.ZMembers("key:queue")
+		return len(members) == 1
+	}, time.Second, 5*time.Millisecond)
+	select {
+	case <-acquired:
+		t.Fatal("second FairLock acquired while the first is held")
+	default:
+	}
+
+	require.NoError(t, first.Unlock(context.Background()))
+	second := <-acquired
+	assert.Greater(t, second.Token(), first.Token())
+	assert.ErrorIs(t, first.Unlock(context.Background()), ErrNotHoldingLock)
+	require.NoError(t, second.Unlock(context.Background()))
+}
+
+func TestClient_FairLockStaleWaiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	// 崩溃的等待者停止心跳，过期后被移出队列
+	now := time.Now()
+	_, err := s.ZAdd("key:queue", float64(now.UnixMilli()), "crashed")
+	require.NoError(t, err)
+	_, err = s.ZAdd("key:alive", float64(now.Add(100*time.Millisecond).UnixMilli()), "crashed")
+	require.NoError(t, err)
+
+	cli := NewClient(rdb, WithValue("waiter"), WithWaitHeartbeat(20*time.Millisecond))
+	start := time.Now()
+	_, err = cli.FairLock(context.Background(), "key")
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
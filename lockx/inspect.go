@@ -0,0 +1,57 @@
+package lockx
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+)
+
+var (
+	//go:embed script/lua/inspect.lua
+	luaInspect string
+	//go:embed script/lua/force_unlock.lua
+	luaForceUnlock string
+	// ErrLockNotFound 锁不存在或者已经过期
+	ErrLockNotFound = errors.New("lock not found")
+)
+
+// LockInfo 锁的持有情况
+type LockInfo struct {
+	// Value 锁的值
+	Value string
+	// Owner 持有者的元数据，锁的值不是 Owner 编码的结果时为 nil
+	Owner *Owner
+	// TTL 锁的剩余过期时间，为负数时表示锁没有过期时间
+	TTL time.Duration
+	// AcquiredAt 获取锁的时间，只有 Lock 和 TryLock 获取的锁会记录，重入不会改变
+	AcquiredAt time.Time
+}
+
+// Inspect 返回锁的持有者和剩余过期时间，锁不存在时返回 ErrLockNotFound。
+// 支持 Lock、TryLock、FairLock 和 ReentrantLock 获取的锁。
+func (cli *Client) Inspect(ctx context.Context, key string) (*LockInfo, error) {
+	info, err := cli.backend.Inspect(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if owner, ok := ParseOwner(info.Value); ok {
+		owner.AcquiredAt = info.AcquiredAt
+		info.Owner = &owner
+	}
+	return &info, nil
+}
+
+// ForceUnlock 不检查持有者强制释放锁，锁不存在时返回 ErrLockNotFound。
+// 只用于运维处理持有者异常的情况，原持有者的续约和释放会返回 ErrNotHoldingLock，
+// 但它可能仍在执行临界区，下游应通过 fencing token 拒绝其写入。
+func (cli *Client) ForceUnlock(ctx context.Context, key string) error {
+	ok, err := cli.backend.ForceUnlock(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotFound
+	}
+	return nil
+}
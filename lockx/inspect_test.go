@@ -0,0 +1,128 @@
+package lockx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestClient_Inspect(t *testing.T) {
+	testCases := []struct {
+		name      string
+		newClient func(t *testing.T, opts ...option.Option[Client]) *Client
+	}{
+		{
+			name: "redis",
+			newClient: func(t *testing.T, opts ...option.Option[Client]) *Client {
+				s := miniredis.RunT(t)
+				return NewClient(redis.NewClient(&redis.Options{Addr: s.Addr()}), opts...)
+			},
+		},
+		{
+			name: "memory",
+			newClient: func(t *testing.T, opts ...option.Option[Client]) *Client {
+				return NewClientWithBackend(NewMemoryBackend(), opts...)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			cli := tc.newClient(t, WithExpiration(10*time.Second))
+
+			_, err := cli.Inspect(ctx, "key")
+			assert.ErrorIs(t, err, ErrLockNotFound)
+
+			start := time.Now()
+			lock, err := cli.Lock(ctx, "key")
+			require.NoError(t, err)
+			info, err := cli.Inspect(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, lock.value, info.Value)
+			assert.InDelta(t, 10*time.Second, info.TTL, float64(time.Second))
+			require.NotNil(t, info.Owner)
+			assert.Equal(t, cli.owner.ID, info.Owner.ID)
+			assert.Equal(t, os.Getpid(), info.Owner.PID)
+			hostname, _ := os.Hostname()
+			assert.Equal(t, hostname, info.Owner.Hostname)
+			assert.WithinDuration(t, start, info.Owner.AcquiredAt, time.Second)
+			assert.Equal(t, info.AcquiredAt, info.Owner.AcquiredAt)
+
+			// 锁的值与获取时间无关，同一个 Client 之后重入使用相同的值，获取时间保持第一次获取的时间
+			time.Sleep(5 * time.Millisecond)
+			again, err := cli.Lock(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, lock.value, again.value)
+			info2, err := cli.Inspect(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, info.Owner.AcquiredAt, info2.Owner.AcquiredAt)
+
+			require.NoError(t, cli.ForceUnlock(ctx, "key"))
+			assert.ErrorIs(t, cli.ForceUnlock(ctx, "key"), ErrLockNotFound)
+			assert.ErrorIs(t, lock.Refresh(ctx), ErrNotHoldingLock)
+			assert.ErrorIs(t, lock.Unlock(ctx), ErrNotHoldingLock)
+			_, err = cli.Inspect(ctx, "key")
+			assert.ErrorIs(t, err, ErrLockNotFound)
+		})
+	}
+}
+
+func TestClient_InspectReentrant(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	cli := NewClient(rdb, WithValue("me"))
+	_, err := cli.ReentrantLock(ctx, "key")
+	require.NoError(t, err)
+	info, err := cli.Inspect(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "me", info.Value)
+	assert.Nil(t, info.Owner)
+
+	// 强制释放公平锁时唤醒等待者
+	_, err = cli.FairLock(ctx, "fair")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewClient(rdb, WithWaitHeartbeat(time.Minute)).FairLock(ctx, "fair")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, cli.ForceUnlock(ctx, "fair"))
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up")
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	owner := Owner{ID: "id", Hostname: "host", PID: 1, AcquiredAt: time.UnixMilli(1700000000000)}
+	testCases := []struct {
+		name      string
+		value     string
+		wantOwner Owner
+		wantOk    bool
+	}{
+		{name: "owner", value: owner.String(), wantOwner: owner, wantOk: true},
+		{name: "without acquired at", value: Owner{ID: "id"}.String(), wantOwner: Owner{ID: "id"}, wantOk: true},
+		{name: "custom value", value: "me"},
+		{name: "json without id", value: `{"hostname":"host"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o, ok := ParseOwner(tc.value)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantOwner, o)
+		})
+	}
+}
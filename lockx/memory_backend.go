@@ -0,0 +1,168 @@
+package lockx
+
+import (
+	"context"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+// MemoryBackend 基于进程内存的后端，锁只在当前进程内有效，
+// 适用于单元测试和单节点部署。
+// 过期的锁在访问时删除，并且定期清理，内存占用只与持有中的锁的数量有关。
+// 所有 key 共用一个 fencing token 计数器，每个 key 上的 token 仍然单调递增，不需要为每个 key 保留计数器。
+type MemoryBackend struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+	// fence 所有 key 共用的 fencing token 计数器
+	fence int64
+	// sweepInterval 清理过期锁的间隔，在调用时检查，不启动额外的 goroutine
+	sweepInterval time.Duration
+	lastSweep     time.Time
+	now           func() time.Time
+}
+
+type memoryLock struct {
+	value      string
+	token      int64
+	acquiredAt time.Time
+	expireAt   time.Time
+}
+
+// NewMemoryBackend 创建 MemoryBackend 实例，默认每分钟清理一次过期的锁。
+func NewMemoryBackend(opts ...option.Option[MemoryBackend]) *MemoryBackend {
+	b := &MemoryBackend{
+		locks:         make(map[string]memoryLock),
+		sweepInterval: time.Minute,
+		now:           time.Now,
+	}
+	option.Options[MemoryBackend](opts).Apply(b)
+	b.lastSweep = b.now()
+	return b
+}
+
+// WithSweepInterval 设置清理过期锁的间隔
+func WithSweepInterval(interval time.Duration) option.Option[MemoryBackend] {
+	return func(b *MemoryBackend) {
+		b.sweepInterval = interval
+	}
+}
+
+// get 返回未过期的锁，过期的锁会被删除，距离上次清理超过 sweepInterval 时清理所有过期的锁
+func (b *MemoryBackend) get(key string) (memoryLock, bool) {
+	now := b.now()
+	if now.Sub(b.lastSweep) >= b.sweepInterval {
+		b.sweep(now)
+	}
+	lock, ok := b.locks[key]
+	if !ok {
+		return lock, false
+	}
+	if !now.Before(lock.expireAt) {
+		delete(b.locks, key)
+		return lock, false
+	}
+	return lock, true
+}
+
+// sweep 删除所有过期的锁
+func (b *MemoryBackend) sweep(now time.Time) {
+	for key, lock := range b.locks {
+		if !now.Before(lock.expireAt) {
+			delete(b.locks, key)
+		}
+	}
+	b.lastSweep = now
+}
+
+func (b *MemoryBackend) Lock(ctx context.Context, key, value string, expiration time.Duration) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.get(key)
+	if ok && lock.value != value {
+		return 0, nil
+	}
+	if !ok {
+		b.fence++
+		lock = memoryLock{value: value, token: b.fence, acquiredAt: b.now()}
+	}
+	lock.expireAt = b.now().Add(expiration)
+	b.locks[key] = lock
+	return lock.token, nil
+}
+
+func (b *MemoryBackend) TryLock(ctx context.Context, key, value string, expiration time.Duration) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.get(key); ok {
+		return 0, nil
+	}
+	b.fence++
+	b.locks[key] = memoryLock{value: value, token: b.fence, acquiredAt: b.now(), expireAt: b.now().Add(expiration)}
+	return b.fence, nil
+}
+
+func (b *MemoryBackend) Unlock(ctx context.Context, key, value string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.get(key)
+	if !ok || lock.value != value {
+		return false, nil
+	}
+	delete(b.locks, key)
+	return true, nil
+}
+
+func (b *MemoryBackend) Refresh(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.get(key)
+	if !ok || lock.value != value {
+		return false, nil
+	}
+	lock.expireAt = b.now().Add(expiration)
+	b.locks[key] = lock
+	return true, nil
+}
+
+func (b *MemoryBackend) Inspect(ctx context.Context, key string) (LockInfo, error) {
+	if ctx.Err() != nil {
+		return LockInfo{}, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.get(key)
+	if !ok {
+		return LockInfo{}, ErrLockNotFound
+	}
+	return LockInfo{Value: lock.value, TTL: lock.expireAt.Sub(b.now()), AcquiredAt: lock.acquiredAt}, nil
+}
+
+func (b *MemoryBackend) ForceUnlock(ctx context.Context, key string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.get(key)
+	delete(b.locks, key)
+	return ok, nil
+}
@@ -0,0 +1,101 @@
+package lockx
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return now }
+
+	cli := NewClientWithBackend(backend, WithValue("me"), WithExpiration(10*time.Second))
+	lock, err := cli.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lock.Token())
+
+	// 同一个持有者再次获取锁，token 不变
+	again, err := cli.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token(), again.Token())
+
+	other := NewClientWithBackend(backend, WithValue("other"))
+	_, err = other.Lock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	now = now.Add(5 * time.Second)
+	require.NoError(t, lock.Refresh(ctx))
+	now = now.Add(9 * time.Second)
+	_, err = other.TryLock(ctx, "key", time.Second, time.Second)
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	require.NoError(t, lock.Unlock(ctx))
+	assert.ErrorIs(t, lock.Unlock(ctx), ErrNotHoldingLock)
+	assert.ErrorIs(t, lock.Refresh(ctx), ErrNotHoldingLock)
+
+	// 锁过期后被其他持有者获取，新的 token 更大
+	lock2, err := other.TryLock(ctx, "key", time.Second, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lock2.Token())
+	now = now.Add(time.Second)
+	assert.ErrorIs(t, lock2.Refresh(ctx), ErrNotHoldingLock)
+	lock3, err := cli.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), lock3.Token())
+
+	_, err = cli.ReentrantLock(ctx, "reentrant")
+	assert.ErrorIs(t, err, ErrBackendNotSupported)
+	_, err = cli.RLock(ctx, "rw")
+	assert.ErrorIs(t, err, ErrBackendNotSupported)
+	_, err = cli.FairLock(ctx, "fair")
+	assert.ErrorIs(t, err, ErrBackendNotSupported)
+}
+
+func TestMemoryBackend_WithLock(t *testing.T) {
+	backend := NewMemoryBackend()
+	cli := NewClientWithBackend(backend, WithExpiration(150*time.Millisecond))
+	other := NewClientWithBackend(backend)
+	err := cli.WithLock(context.Background(), "key", func(ctx context.Context) error {
+		// 执行时间超过过期时间，依赖自动续约
+		time.Sleep(400 * time.Millisecond)
+		_, err := other.TryLock(ctx, "key", time.Second, time.Second)
+		assert.ErrorIs(t, err, ErrGetLockFailed)
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = other.TryLock(context.Background(), "key", time.Second, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestMemoryBackend_Sweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backend := NewMemoryBackend(WithSweepInterval(time.Minute))
+	backend.now = func() time.Time { return now }
+
+	cli := NewClientWithBackend(backend, WithExpiration(time.Second))
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := cli.Lock(ctx, key)
+		require.NoError(t, err)
+	}
+	assert.Len(t, backend.locks, 3)
+
+	// 过期的锁在访问时删除
+	now = now.Add(2 * time.Second)
+	_, err := cli.Inspect(ctx, "a")
+	assert.ErrorIs(t, err, ErrLockNotFound)
+	assert.Len(t, backend.locks, 2)
+
+	// 超过清理间隔之后所有过期的锁都被删除，不同 key 的 token 共用计数器
+	now = now.Add(time.Minute)
+	lock, err := cli.Lock(ctx, "d")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), lock.Token())
+	assert.Len(t, backend.locks, 1)
+}
@@ -0,0 +1,60 @@
+package lockx
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"os"
+	"time"
+)
+
+// Owner 锁持有者的元数据，未使用 WithValue 时编码为 JSON 作为锁的值，
+// 便于排查问题时通过 Client.Inspect 知道锁被谁持有、持有了多久。
+type Owner struct {
+	// ID 持有者的唯一标识，每个 Client 不同
+	ID       string
+	Hostname string
+	PID      int
+	// AcquiredAt 获取锁的时间，不编码到锁的值中，由 Client.Inspect 填充。
+	// 只有 Lock 和 TryLock 获取的锁会记录
+	AcquiredAt time.Time
+}
+
+type ownerJSON struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+	// AcquiredAt 毫秒时间戳
+	AcquiredAt int64 `json:"acquired_at,omitempty"`
+}
+
+func newOwner() *Owner {
+	hostname, _ := os.Hostname()
+	return &Owner{
+		ID:       uuid.New().String(),
+		Hostname: hostname,
+		PID:      os.Getpid(),
+	}
+}
+
+// String 返回 Owner 编码后的锁的值
+func (o Owner) String() string {
+	val := ownerJSON{ID: o.ID, Hostname: o.Hostname, PID: o.PID}
+	if !o.AcquiredAt.IsZero() {
+		val.AcquiredAt = o.AcquiredAt.UnixMilli()
+	}
+	data, _ := json.Marshal(val)
+	return string(data)
+}
+
+// ParseOwner 解析锁的值，值不是 Owner 编码的结果时（例如使用了 WithValue）返回 false
+func ParseOwner(value string) (Owner, bool) {
+	var val ownerJSON
+	if err := json.Unmarshal([]byte(value), &val); err != nil || val.ID == "" {
+		return Owner{}, false
+	}
+	o := Owner{ID: val.ID, Hostname: val.Hostname, PID: val.PID}
+	if val.AcquiredAt > 0 {
+		o.AcquiredAt = time.UnixMilli(val.AcquiredAt)
+	}
+	return o, true
+}
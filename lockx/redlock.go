@@ -0,0 +1,181 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"sync"
+	"time"
+)
+
+const (
+	// redlockDriftFactor 时钟漂移系数，锁的有效时间需要扣除 expiration * redlockDriftFactor
+	redlockDriftFactor = 0.01
+	// redlockMinDrift 最小时钟漂移
+	redlockMinDrift = 2 * time.Millisecond
+)
+
+// Redlock 基于多个相互独立的 redis 节点实现的分布式锁（Redlock 算法）。
+// 只有在多数节点上加锁成功，并且加锁耗时加上时钟漂移小于过期时间，才认为获取锁成功，
+// 因此少数节点宕机或主从切换不会导致锁失效。
+type Redlock struct {
+	clients []redis.Cmdable
+	// quorum 多数派节点数
+	quorum int
+	// cfg 复用 Client 的配置：value、expiration、timeout 和 retry
+	cfg *Client
+}
+
+// NewRedlock 创建 Redlock 实例。
+// clients: 相互独立的 redis 节点，不能是同一个集群的主从节点，建议为奇数个。
+// opts: 与 NewClient 的选项相同，timeout 为单个节点的调用超时，应远小于 expiration。
+func NewRedlock(clients []redis.Cmdable, opts ...option.Option[Client]) *Redlock {
+	if len(clients) == 0 {
+		panic("clients must not be empty")
+	}
+	cfg := NewClient(nil, append([]option.Option[Client]{WithTimeout(50 * time.Millisecond)}, opts...)...)
+	return &Redlock{
+		clients: clients,
+		quorum:  len(clients)/2 + 1,
+		cfg:     cfg,
+	}
+}
+
+// Lock 在多数节点上获取锁，失败时按照重试策略重试。
+func (r *Redlock) Lock(ctx context.Context, key string) (*Lock, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	for {
+		lock, err := r.tryLock(ctx, key)
+		if err == nil {
+			return lock, nil
+		}
+
+		if r.cfg.retry != nil {
+			nextInterval, canRetry := r.cfg.retry.Next()
+			if !canRetry {
+				return nil, fmt.Errorf("重试机会耗尽，%w", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(nextInterval):
+				continue
+			}
+		}
+		return nil, err
+	}
+}
+
+// TryLock 尝试在多数节点上获取锁，不重试
+func (r *Redlock) TryLock(ctx context.Context, key string) (*Lock, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return r.tryLock(ctx, key)
+}
+
+func (r *Redlock) tryLock(ctx context.Context, key string) (*Lock, error) {
+	start := time.Now()
+	// 加锁只使用 SET NX PX，不依赖 lua 脚本
+	n, err := r.eachClient(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		return client.SetNX(ctx, key, r.cfg.value, r.cfg.expiration).Result()
+	})
+
+	if n >= r.quorum && r.validity(start) > 0 {
+		return r.newLock(key), nil
+	}
+
+	// 未获取到多数节点或者已经过期，释放所有节点上的锁
+	rct, cancel := context.WithTimeout(context.Background(), r.cfg.timeout)
+	_, _ = r.eachClient(rct, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		return unlockOn(ctx, client, key, r.cfg.value)
+	})
+	cancel()
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGetLockFailed, err)
+	}
+	return nil, ErrGetLockFailed
+}
+
+// validity 返回锁剩余的有效时间
+func (r *Redlock) validity(start time.Time) time.Duration {
+	drift := time.Duration(float64(r.cfg.expiration)*redlockDriftFactor) + redlockMinDrift
+	return r.cfg.expiration - time.Since(start) - drift
+}
+
+func (r *Redlock) newLock(key string) *Lock {
+	lock := newLock(nil, key, r.cfg.value, r.cfg.expiration, r.cfg.timeout)
+	lock.redlock = r
+	return lock
+}
+
+// unlock 释放所有节点上的锁，成功释放的节点数少于多数派时返回 ErrNotHoldingLock
+func (r *Redlock) unlock(ctx context.Context, key, value string) error {
+	n, err := r.eachClient(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		return unlockOn(ctx, client, key, value)
+	})
+	if n >= r.quorum {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotHoldingLock, err)
+	}
+	return ErrNotHoldingLock
+}
+
+// refresh 在所有节点上续约，成功续约的节点数少于多数派或续约耗时过长时返回 ErrNotHoldingLock
+func (r *Redlock) refresh(ctx context.Context, key, value string, expiration time.Duration) error {
+	start := time.Now()
+	n, err := r.eachClient(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		res, err := client.Eval(ctx, luaRefresh, []string{key}, value, expiration.Milliseconds()).Int64()
+		return res == 1, err
+	})
+	if n >= r.quorum && r.validity(start) > 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotHoldingLock, err)
+	}
+	return ErrNotHoldingLock
+}
+
+// eachClient 并发地在每个节点上执行 fn，返回执行成功的节点数和遇到的第一个错误
+func (r *Redlock) eachClient(ctx context.Context, fn func(ctx context.Context, client redis.Cmdable) (bool, error)) (int, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		n        int
+		firstErr error
+	)
+	for _, client := range r.clients {
+		wg.Add(1)
+		go func(client redis.Cmdable) {
+			defer wg.Done()
+			rct, cancel := context.WithTimeout(ctx, r.cfg.timeout)
+			ok, err := fn(rct, client)
+			cancel()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && !errors.Is(err, redis.Nil) && firstErr == nil {
+				firstErr = err
+			}
+			if ok {
+				n++
+			}
+		}(client)
+	}
+	wg.Wait()
+	return n, firstErr
+}
+
+func unlockOn(ctx context.Context, client redis.Cmdable, key, value string) (bool, error) {
+	res, err := client.Eval(ctx, luaUnlock, []string{key}, value).Int64()
+	return res == 1, err
+}
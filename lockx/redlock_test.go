@@ -0,0 +1,116 @@
+package lockx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newRedlockNodes(t *testing.T, n int) ([]*miniredis.Miniredis, []redis.Cmdable) {
+	servers := make([]*miniredis.Miniredis, 0, n)
+	clients := make([]redis.Cmdable, 0, n)
+	for i := 0; i < n; i++ {
+		s := miniredis.RunT(t)
+		servers = append(servers, s)
+		clients = append(clients, redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	}
+	return servers, clients
+}
+
+func TestRedlock(t *testing.T) {
+	testCases := []struct {
+		name    string
+		down    int
+		locked  int
+		wantErr error
+	}{
+		{
+			name: "all nodes available",
+		},
+		{
+			name: "minority nodes down",
+			down: 1,
+		},
+		{
+			name:    "majority nodes down",
+			down:    2,
+			wantErr: ErrGetLockFailed,
+		},
+		{
+			name:   "minority nodes locked by others",
+			locked: 1,
+		},
+		{
+			name:    "majority nodes locked by others",
+			locked:  2,
+			wantErr: ErrGetLockFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			servers, clients := newRedlockNodes(t, 3)
+			for i := 0; i < tc.down; i++ {
+				servers[i].Close()
+			}
+			for i := 0; i < tc.locked; i++ {
+				require.NoError(t, servers[i].Set("key", "other"))
+			}
+
+			rl := NewRedlock(clients, WithValue("me"), WithExpiration(time.Second))
+			lock, err := rl.Lock(context.Background(), "key")
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				// 获取失败时，已经加上的锁要被释放
+				for i := tc.down; i < len(servers); i++ {
+					if i >= tc.locked {
+						assert.False(t, servers[i].Exists("key"))
+					}
+				}
+				return
+			}
+
+			for i := tc.down; i < len(servers); i++ {
+				if i >= tc.locked {
+					got, _ := servers[i].Get("key")
+					assert.Equal(t, "me", got)
+				}
+			}
+
+			servers[len(servers)-1].FastForward(500 * time.Millisecond)
+			require.NoError(t, lock.Refresh(context.Background()))
+			assert.Equal(t, time.Second, servers[len(servers)-1].TTL("key"))
+
+			require.NoError(t, lock.Unlock(context.Background()))
+			for i := tc.down; i < len(servers); i++ {
+				if i >= tc.locked {
+					assert.False(t, servers[i].Exists("key"))
+				}
+			}
+		})
+	}
+}
+
+func TestRedlockContention(t *testing.T) {
+	_, clients := newRedlockNodes(t, 5)
+
+	first := NewRedlock(clients, WithExpiration(time.Second))
+	lock, err := first.Lock(context.Background(), "key")
+	require.NoError(t, err)
+
+	second := NewRedlock(clients, WithExpiration(time.Second))
+	_, err = second.TryLock(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	// 已经释放的锁不能被再次释放
+	require.NoError(t, lock.Unlock(context.Background()))
+	assert.ErrorIs(t, lock.Unlock(context.Background()), ErrNotHoldingLock)
+	assert.ErrorIs(t, lock.Refresh(context.Background()), ErrNotHoldingLock)
+
+	_, err = second.TryLock(context.Background(), "key")
+	assert.NoError(t, err)
+}
@@ -5,7 +5,6 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/shijting/kit"
 	"github.com/shijting/kit/option"
@@ -15,10 +14,18 @@ import (
 var (
 	//go:embed script/lua/lock.lua
 	luaLock string
+	//go:embed script/lua/try_lock.lua
+	luaTryLock string
 	//go:embed script/lua/unlock.lua
 	luaUnlock string
 	//go:embed script/lua/refresh.lua
 	luaRefresh string
+	//go:embed script/lua/reentrant_lock.lua
+	luaReentrantLock string
+	//go:embed script/lua/reentrant_unlock.lua
+	luaReentrantUnlock string
+	//go:embed script/lua/reentrant_refresh.lua
+	luaReentrantRefresh string
 	// ErrReleaseLock 释放锁错误
 	ErrReleaseLock = errors.New("release lock error")
 	// ErrNotHoldingLock 未持有锁
@@ -29,32 +36,57 @@ var (
 	ErrorNotGetLock = errors.New("not get lock")
 )
 
+// Client 分布式锁客户端。
+// 为了分配单调递增的 fencing token，每个锁的 key 在 redis 中对应一个 "key:fence" 计数器，
+// 计数器不会过期，锁释放之后仍然保留，使用大量不同的 key 时需要注意 redis 的内存占用。
 type Client struct {
-	client redis.Cmdable
+	// client 可重入锁、读写锁和公平锁等依赖 redis 的功能使用，使用其他后端时为 nil
+	client  redis.Cmdable
+	backend Backend
 	// value setnx 的值
 	value string
+	// owner 未使用 WithValue 时的持有者元数据，编码后作为 value
+	owner *Owner
 	// expiration 锁的过期时间
 	expiration time.Duration
 	// timeout 调用redis的超时时间
 	timeout time.Duration
 	retry   kit.RetryStrategy
+	// waitHeartbeat 公平锁等待者的心跳间隔
+	waitHeartbeat time.Duration
 }
 
 func NewClient(client redis.Cmdable, opts ...option.Option[Client]) *Client {
+	cli := newClient(NewRedisBackend(client), opts...)
+	cli.client = client
+	return cli
+}
+
+// NewClientWithBackend 使用指定的后端创建 Client，例如 NewMemoryBackend 创建的内存后端。
+// 非 redis 后端只支持 Lock、TryLock 和 WithLock，其他类型的锁返回 ErrBackendNotSupported。
+func NewClientWithBackend(backend Backend, opts ...option.Option[Client]) *Client {
+	return newClient(backend, opts...)
+}
+
+func newClient(backend Backend, opts ...option.Option[Client]) *Client {
+	owner := newOwner()
 	cli := &Client{
-		client:     client,
-		value:      uuid.New().String(),
-		expiration: 30 * time.Second,
-		timeout:    3 * time.Second,
+		backend:       backend,
+		value:         owner.String(),
+		owner:         owner,
+		expiration:    30 * time.Second,
+		timeout:       3 * time.Second,
+		waitHeartbeat: time.Second,
 	}
 	option.Options[Client](opts).Apply(cli)
 	return cli
 }
 
-// WithValue 设置 setnx 的值
+// WithValue 设置 setnx 的值，代替默认的 Owner 元数据
 func WithValue(value string) option.Option[Client] {
 	return func(t *Client) {
 		t.value = value
+		t.owner = nil
 	}
 }
 
@@ -72,6 +104,13 @@ func WithTimeout(timeout time.Duration) option.Option[Client] {
 	}
 }
 
+// WithWaitHeartbeat 设置公平锁等待者的心跳间隔，也是收不到释放通知时的轮询间隔
+func WithWaitHeartbeat(interval time.Duration) option.Option[Client] {
+	return func(t *Client) {
+		t.waitHeartbeat = interval
+	}
+}
+
 // WithRetry 设置重试策略
 func WithRetry(retry kit.RetryStrategy) option.Option[Client] {
 	return func(t *Client) {
@@ -79,21 +118,63 @@ func WithRetry(retry kit.RetryStrategy) option.Option[Client] {
 	}
 }
 
+// Lock 获取锁，失败时按照重试策略重试。
+// 每次获取成功都会分配一个单调递增的 fencing token，通过 Lock.Token 获取。
 func (cli *Client) Lock(ctx context.Context, key string) (*Lock, error) {
+	var token int64
+	err := cli.acquire(ctx, func(ctx context.Context) (bool, error) {
+		var err error
+		token, err = cli.backend.Lock(ctx, key, cli.value, cli.expiration)
+		return token > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	lock := cli.newLock(key, cli.value, cli.expiration, cli.timeout)
+	lock.token = token
+	return lock, nil
+}
+
+// ReentrantLock 获取可重入锁，锁保存在 redis hash 中，记录持有者和持有次数。
+// 同一个持有者（value 相同）可以多次获取，每次获取持有次数加一，
+// 每次 Unlock 持有次数减一，减到 0 时才真正释放锁。
+// 可重入锁与 Lock 获取的普通锁不能作用于同一个 key。
+// 重入时返回与第一次获取相同的 fencing token。
+func (cli *Client) ReentrantLock(ctx context.Context, key string) (*Lock, error) {
+	if cli.client == nil {
+		return nil, ErrBackendNotSupported
+	}
+	var token int64
+	err := cli.acquire(ctx, func(ctx context.Context) (bool, error) {
+		var err error
+		token, err = cli.client.Eval(ctx, luaReentrantLock, []string{key, fenceKey(key)}, cli.value, cli.expiration.Milliseconds()).Int64()
+		return token > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	lock := newLock(cli.client, key, cli.value, cli.expiration, cli.timeout)
+	lock.reentrant = true
+	lock.token = token
+	return lock, nil
+}
+
+// acquire 调用 try 抢锁，失败时按照重试策略重试
+func (cli *Client) acquire(ctx context.Context, try func(ctx context.Context) (bool, error)) error {
 	if ctx.Err() != nil {
-		return nil, ctx.Err()
+		return ctx.Err()
 	}
 
 	for {
 		rct, cancel := context.WithTimeout(ctx, cli.timeout)
-		res, err := cli.client.Eval(rct, luaLock, []string{key}, cli.value, cli.expiration.Seconds()).Result()
+		ok, err := try(rct)
 		cancel()
 		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
-			return nil, err
+			return err
 		}
 
-		if res == "OK" {
-			return newLock(cli.client, key, cli.value, cli.expiration, cli.timeout), nil
+		if ok {
+			return nil
 		}
 
 		if cli.retry != nil {
@@ -104,44 +185,66 @@ func (cli *Client) Lock(ctx context.Context, key string) (*Lock, error) {
 				} else {
 					err = fmt.Errorf("未抢到锁: %w", ErrorNotGetLock)
 				}
-				return nil, fmt.Errorf("重试机会耗尽，%w", err)
+				return fmt.Errorf("重试机会耗尽，%w", err)
 			}
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return ctx.Err()
 			case <-time.After(nextInterval):
 				continue
 			}
 		}
-		return nil, ErrGetLockFailed
+		return ErrGetLockFailed
 	}
 }
 
-// TryLock 尝试获取锁, 不一定能获取到
+// TryLock 尝试获取锁, 不一定能获取到。
+// 只在 key 未被持有时获取锁（SETNX 语义），锁已经被自己持有（同一个 Client 或者 WithValue 相同）时同样失败。
 func (cli *Client) TryLock(ctx context.Context, key string, expire, timeout time.Duration) (*Lock, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
-	rct, cancel := context.WithTimeout(ctx, cli.timeout)
-	ok, err := cli.client.SetNX(rct, key, cli.value, cli.expiration).Result()
+	rct, cancel := context.WithTimeout(ctx, timeout)
+	token, err := cli.backend.TryLock(rct, key, cli.value, expire)
 	cancel()
 	if err != nil {
 		return nil, err
 	}
 
-	if !ok {
+	if token <= 0 {
 		return nil, ErrGetLockFailed
 	}
-	return newLock(cli.client, key, cli.value, expire, timeout), nil
+	lock := cli.newLock(key, cli.value, expire, timeout)
+	lock.token = token
+	return lock, nil
+
+}
 
+// fenceKey 返回 fencing token 计数器的 key，计数器不会过期，保证 token 单调递增。
+// redis 集群下需要在 key 中使用 hash tag，保证计数器与锁位于同一个槽。
+func fenceKey(key string) string {
+	return key + ":fence"
 }
 
 type Lock struct {
 	client     redis.Cmdable
+	backend    Backend
 	key        string
 	value      string
 	expiration time.Duration
 	timeout    time.Duration
+	// redlock 非空时表示 Redlock 获取的锁，续约和释放作用于所有节点
+	redlock *Redlock
+	// reentrant 是否为可重入锁
+	reentrant bool
+	// rwField 非空时表示读写锁，为持有者在 hash 中的字段名
+	rwField string
+	// fair 是否为公平锁
+	fair bool
+	// semaphore 是否为信号量许可
+	semaphore bool
+	// token fencing token，为 0 时表示该类型的锁不支持 fencing token
+	token int64
 }
 
 func newLock(client redis.Cmdable, key, value string, expiration, timeout time.Duration) *Lock {
@@ -154,20 +257,60 @@ func newLock(client redis.Cmdable, key, value string, expiration, timeout time.D
 	}
 }
 
+// newLock 创建通过后端获取的锁
+func (cli *Client) newLock(key, value string, expiration, timeout time.Duration) *Lock {
+	lock := newLock(cli.client, key, value, expiration, timeout)
+	lock.backend = cli.backend
+	return lock
+}
+
+// Token 返回获取锁时分配的 fencing token，续约不会改变 token。
+// 下游存储可以拒绝 token 小于已见过的最大 token 的写入，避免锁过期后旧的持有者写入数据。
+// 读写锁和 Redlock 不支持 fencing token，返回 0。
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
 // Unlock 释放锁
 func (l *Lock) Unlock(ctx context.Context) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	res, err := l.client.Eval(ctx, luaUnlock, []string{l.key}, l.value).Int64()
-
-	if err == redis.Nil || res != 1 {
-		return ErrNotHoldingLock
+	if l.redlock != nil {
+		return l.redlock.unlock(ctx, l.key, l.value)
+	}
+	if l.reentrant {
+		return l.reentrantUnlock(ctx)
+	}
+	if l.rwField != "" {
+		return l.rwUnlock(ctx)
 	}
+	if l.fair {
+		return l.fairUnlock(ctx)
+	}
+	if l.semaphore {
+		return l.semaphoreRelease(ctx)
+	}
+	ok, err := l.backend.Unlock(ctx, l.key, l.value)
 	if err != nil {
 		// TODO: 其他未知错误，要不要重试？
 		return err
 	}
+	if !ok {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
+
+// reentrantUnlock 可重入锁的持有次数减一，减到 0 时删除锁
+func (l *Lock) reentrantUnlock(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaReentrantUnlock, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res < 0 {
+		return ErrNotHoldingLock
+	}
 	return nil
 }
 
@@ -176,14 +319,37 @@ func (l *Lock) Refresh(ctx context.Context) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	res, err := l.client.Eval(ctx, luaRefresh, []string{l.key}, l.value, l.expiration.Seconds()).Int64()
-
-	if err == redis.Nil || res == 0 {
+	if l.redlock != nil {
+		return l.redlock.refresh(ctx, l.key, l.value, l.expiration)
+	}
+	if l.rwField != "" {
+		return l.rwRefresh(ctx)
+	}
+	if l.semaphore {
+		return l.semaphoreRefresh(ctx)
+	}
+	if l.reentrant {
+		return l.reentrantRefresh(ctx)
+	}
+	ok, err := l.backend.Refresh(ctx, l.key, l.value, l.expiration)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return ErrNotHoldingLock
 	}
+	return nil
+}
+
+// reentrantRefresh 刷新可重入锁的过期时间
+func (l *Lock) reentrantRefresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaReentrantRefresh, []string{l.key}, l.value, l.expiration.Milliseconds()).Int64()
 	if err != nil {
 		return err
 	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
 	return nil
 }
 
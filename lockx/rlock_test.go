@@ -1 +1,178 @@
 package lockx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClient_Lock(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	cli := NewClient(rdb, WithValue("me"), WithExpiration(10*time.Second))
+	lock, err := cli.Lock(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, s.TTL("key"))
+
+	// 其他持有者抢锁失败
+	other := NewClient(rdb, WithValue("other"))
+	_, err = other.Lock(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	s.FastForward(5 * time.Second)
+	require.NoError(t, lock.Refresh(context.Background()))
+	assert.Equal(t, 10*time.Second, s.TTL("key"))
+
+	require.NoError(t, lock.Unlock(context.Background()))
+	assert.False(t, s.Exists("key"))
+	assert.ErrorIs(t, lock.Unlock(context.Background()), ErrNotHoldingLock)
+	assert.ErrorIs(t, lock.Refresh(context.Background()), ErrNotHoldingLock)
+}
+
+func TestClient_ReentrantLock(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	cli := NewClient(rdb, WithValue("me"), WithExpiration(10*time.Second))
+	outer, err := cli.ReentrantLock(context.Background(), "key")
+	require.NoError(t, err)
+	inner, err := cli.ReentrantLock(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "2", s.HGet("key", "count"))
+
+	other := NewClient(rdb, WithValue("other"))
+	_, err = other.ReentrantLock(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	s.FastForward(5 * time.Second)
+	require.NoError(t, inner.Refresh(context.Background()))
+	assert.Equal(t, 10*time.Second, s.TTL("key"))
+
+	// 内层释放后锁仍然被持有
+	require.NoError(t, inner.Unlock(context.Background()))
+	assert.Equal(t, "1", s.HGet("key", "count"))
+	_, err = other.ReentrantLock(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	require.NoError(t, outer.Unlock(context.Background()))
+	assert.False(t, s.Exists("key"))
+	assert.ErrorIs(t, outer.Unlock(context.Background()), ErrNotHoldingLock)
+
+	_, err = other.ReentrantLock(context.Background(), "key")
+	assert.NoError(t, err)
+}
+
+func TestLock_Token(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	first := NewClient(rdb, WithValue("first"), WithExpiration(time.Second))
+	lock1, err := first.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lock1.Token())
+
+	// 同一个持有者再次获取锁，token 不变
+	again, err := first.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, lock1.Token(), again.Token())
+
+	// 锁过期后被其他持有者获取，新的 token 更大
+	s.FastForward(2 * time.Second)
+	second := NewClient(rdb, WithValue("second"))
+	lock2, err := second.TryLock(ctx, "key", time.Second, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lock2.Token())
+	require.NoError(t, lock2.Refresh(ctx))
+	assert.Equal(t, int64(2), lock2.Token())
+	assert.ErrorIs(t, lock1.Refresh(ctx), ErrNotHoldingLock)
+	require.NoError(t, lock2.Unlock(ctx))
+
+	reentrant, err := first.ReentrantLock(ctx, "reentrant")
+	require.NoError(t, err)
+	nested, err := first.ReentrantLock(ctx, "reentrant")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reentrant.Token())
+	assert.Equal(t, reentrant.Token(), nested.Token())
+
+	fair, err := second.FairLock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fair.Token())
+}
+
+func TestClient_TryLockExclusive(t *testing.T) {
+	testCases := []struct {
+		name      string
+		newClient func(t *testing.T) *Client
+	}{
+		{
+			name: "redis",
+			newClient: func(t *testing.T) *Client {
+				s := miniredis.RunT(t)
+				return NewClient(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+			},
+		},
+		{
+			name: "memory",
+			newClient: func(t *testing.T) *Client {
+				return NewClientWithBackend(NewMemoryBackend())
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			cli := tc.newClient(t)
+
+			// 同一个 Client 的两次 TryLock 互斥，不会被当作重入
+			lock, err := cli.TryLock(ctx, "key", time.Second, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), lock.Token())
+			_, err = cli.TryLock(ctx, "key", time.Second, time.Second)
+			assert.ErrorIs(t, err, ErrGetLockFailed)
+
+			require.NoError(t, lock.Unlock(ctx))
+			lock, err = cli.TryLock(ctx, "key", time.Second, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), lock.Token())
+		})
+	}
+}
+
+func TestLock_TokenMissingFence(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	cli := NewClient(rdb, WithValue("me"))
+
+	// 锁在 fencing token 引入之前写入，或者计数器被淘汰，重入时重新分配 token
+	s.Set("key", "me")
+	lock, err := cli.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lock.Token())
+
+	s.Del("key:fence")
+	lock, err = cli.Lock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lock.Token())
+
+	s.HSet("reentrant", "owner", "me", "count", "1")
+	reentrant, err := cli.ReentrantLock(ctx, "reentrant")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reentrant.Token())
+	assert.Equal(t, "1", s.HGet("reentrant", "token"))
+
+	// 公平锁同一次获取的重试
+	fairCli := NewClient(rdb, WithValue("fair"))
+	token, err := fairCli.tryFairLock(ctx, "fair", "holder")
+	require.NoError(t, err)
+	s.Del("fair:fence")
+	again, err := fairCli.tryFairLock(ctx, "fair", "holder")
+	require.NoError(t, err)
+	assert.Equal(t, token, again)
+}
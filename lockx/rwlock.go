@@ -0,0 +1,105 @@
+package lockx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	//go:embed script/lua/rw_rlock.lua
+	luaRWRLock string
+	//go:embed script/lua/rw_wlock.lua
+	luaRWWLock string
+	//go:embed script/lua/rw_unlock.lua
+	luaRWUnlock string
+	//go:embed script/lua/rw_refresh.lua
+	luaRWRefresh string
+)
+
+const (
+	rwReaderPrefix  = "r:"
+	rwWriterPrefix  = "w:"
+	rwWaitingPrefix = "q:"
+)
+
+// RLock 获取读锁，多个读者可以同时持有同一个 key 的读锁。
+// 读写锁保存在 redis hash 中，每个持有者都有各自的过期时间，持有者崩溃后其读锁会自动过期。
+// 写者优先：写锁被持有或者有写者在等待时，新的读者无法获取读锁。
+// 读写锁与 Lock 获取的普通锁不能作用于同一个 key，各节点的时钟偏差应远小于过期时间。
+// 每次获取都是独立的持有者，同一个 Client 的多个读者各自释放，互不影响。
+func (cli *Client) RLock(ctx context.Context, key string) (*Lock, error) {
+	if cli.client == nil {
+		return nil, ErrBackendNotSupported
+	}
+	holder := cli.rwHolder()
+	err := cli.acquire(ctx, func(ctx context.Context) (bool, error) {
+		res, err := cli.client.Eval(ctx, luaRWRLock, []string{key},
+			holder, cli.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+		return res == 1, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cli.newRWLock(key, rwReaderPrefix, holder), nil
+}
+
+// WLock 获取写锁，写锁与其他写锁、读锁互斥。
+// 获取失败时登记为等待中的写者，阻止新的读者进入，最终放弃时移除登记。
+// 与 RLock 相同，每次获取都是独立的持有者，同一个 Client 的两次 WLock 同样互斥。
+func (cli *Client) WLock(ctx context.Context, key string) (*Lock, error) {
+	if cli.client == nil {
+		return nil, ErrBackendNotSupported
+	}
+	holder := cli.rwHolder()
+	err := cli.acquire(ctx, func(ctx context.Context) (bool, error) {
+		res, err := cli.client.Eval(ctx, luaRWWLock, []string{key},
+			holder, cli.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+		return res == 1, err
+	})
+	if err != nil {
+		rct, cancel := context.WithTimeout(context.Background(), cli.timeout)
+		cli.client.HDel(rct, key, rwWaitingPrefix+holder)
+		cancel()
+		return nil, err
+	}
+	return cli.newRWLock(key, rwWriterPrefix, holder), nil
+}
+
+// rwHolder 返回一次读写锁获取的持有者，与 Semaphore 相同在 value 之后加上随机的后缀，
+// 同一次获取的重试使用相同的持有者
+func (cli *Client) rwHolder() string {
+	return cli.value + ":" + uuid.New().String()
+}
+
+func (cli *Client) newRWLock(key, prefix, holder string) *Lock {
+	lock := newLock(cli.client, key, holder, cli.expiration, cli.timeout)
+	lock.rwField = prefix + holder
+	return lock
+}
+
+// rwUnlock 释放读锁或写锁
+func (l *Lock) rwUnlock(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaRWUnlock, []string{l.key}, l.rwField).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
+
+// rwRefresh 刷新读锁或写锁的过期时间
+func (l *Lock) rwRefresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaRWRefresh, []string{l.key},
+		l.rwField, l.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package lockx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClient_RWLock(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	reader1 := NewClient(rdb, WithValue("reader1"))
+	reader2 := NewClient(rdb, WithValue("reader2"))
+	reader3 := NewClient(rdb, WithValue("reader3"))
+	writer := NewClient(rdb, WithValue("writer"))
+
+	// 多个读者可以同时持有读锁
+	r1, err := reader1.RLock(ctx, "key")
+	require.NoError(t, err)
+	r2, err := reader2.RLock(ctx, "key")
+	require.NoError(t, err)
+
+	// 有读者时写者获取失败，并登记为等待中的写者
+	holder := writer.rwHolder()
+	_, err = writer.acquireWLockOnce(ctx, "key", holder)
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+	assert.True(t, s.Exists("key"))
+	assert.NotEmpty(t, s.HGet("key", "q:"+holder))
+
+	// 写者优先，有写者等待时新的读者获取失败
+	_, err = reader3.RLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	require.NoError(t, r1.Refresh(ctx))
+	require.NoError(t, r1.Unlock(ctx))
+	require.NoError(t, r2.Unlock(ctx))
+	assert.ErrorIs(t, r2.Unlock(ctx), ErrNotHoldingLock)
+
+	// 模拟的写者放弃等待
+	s.HDel("key", "q:"+holder)
+	w, err := writer.WLock(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []string{w.rwField}, hashFields(t, s, "key"))
+
+	_, err = reader1.RLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+	_, err = NewClient(rdb, WithValue("writer2")).WLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	require.NoError(t, w.Refresh(ctx))
+	require.NoError(t, w.Unlock(ctx))
+	assert.ErrorIs(t, w.Refresh(ctx), ErrNotHoldingLock)
+
+	// writer2 放弃后不再阻止读者
+	_, err = reader3.RLock(ctx, "key")
+	assert.NoError(t, err)
+}
+
+func TestClient_RWLockExpiration(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	crashed := NewClient(rdb, WithValue("crashed"), WithExpiration(50*time.Millisecond))
+	alive := NewClient(rdb, WithValue("alive"), WithExpiration(time.Second))
+	writer := NewClient(rdb, WithValue("writer"))
+
+	_, err := crashed.RLock(ctx, "key")
+	require.NoError(t, err)
+	r, err := alive.RLock(ctx, "key")
+	require.NoError(t, err)
+	require.NoError(t, r.Unlock(ctx))
+
+	_, err = writer.WLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	// 崩溃的读者过期后，写者可以获取写锁
+	time.Sleep(100 * time.Millisecond)
+	_, err = writer.WLock(ctx, "key")
+	assert.NoError(t, err)
+	for _, field := range hashFields(t, s, "key") {
+		assert.NotContains(t, field, "r:crashed")
+	}
+}
+
+func TestClient_RWLockSameClient(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	cli := NewClient(rdb, WithValue("reader"))
+	writer := NewClient(rdb, WithValue("writer"))
+
+	// 同一个 Client 的两个读者各自持有读锁
+	r1, err := cli.RLock(ctx, "key")
+	require.NoError(t, err)
+	r2, err := cli.RLock(ctx, "key")
+	require.NoError(t, err)
+	require.NoError(t, r1.Unlock(ctx))
+
+	// r2 仍在读，写者获取失败
+	_, err = writer.WLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+	require.NoError(t, r2.Unlock(ctx))
+
+	// 同一个 Client 的两次 WLock 互斥
+	w, err := cli.WLock(ctx, "key")
+	require.NoError(t, err)
+	_, err = cli.WLock(ctx, "key")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+	require.NoError(t, w.Unlock(ctx))
+}
+
+// acquireWLockOnce 获取写锁失败时保留等待登记，模拟仍在重试中的写者
+func (cli *Client) acquireWLockOnce(ctx context.Context, key, holder string) (*Lock, error) {
+	res, err := cli.client.Eval(ctx, luaRWWLock, []string{key},
+		holder, cli.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if res != 1 {
+		return nil, ErrGetLockFailed
+	}
+	return cli.newRWLock(key, rwWriterPrefix, holder), nil
+}
+
+// hashFields 返回 hash 的所有字段，key 不存在时为空
+func hashFields(t *testing.T, s *miniredis.Miniredis, key string) []string {
+	if !s.Exists(key) {
+		return nil
+	}
+	fields, err := s.HKeys(key)
+	require.NoError(t, err)
+	return fields
+}
@@ -0,0 +1,6 @@
+-- 等待者放弃等待：KEYS[1] 等待队列，KEYS[2] 等待者心跳，KEYS[3] 释放通知的频道
+redis.call('zrem', KEYS[1], ARGV[1])
+redis.call('zrem', KEYS[2], ARGV[1])
+-- 通知后面的等待者重新竞争
+redis.call('publish', KEYS[3], ARGV[1])
+return 1
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- KEYS[1] 锁，KEYS[2] 等待队列（score 为入队时间），KEYS[3] 等待者心跳（score 为过期时间），KEYS[4] fencing token 计数器
+-- ARGV[1] 本次获取的持有者，每次获取各不相同，ARGV[2] 锁过期时间（毫秒），ARGV[3] 当前时间（毫秒），ARGV[4] 等待者过期时间（毫秒）
+local value = ARGV[1]
+local now = tonumber(ARGV[3])
+local waitTTL = tonumber(ARGV[4])
+
+-- 清理心跳过期的等待者
+local stale = redis.call('zrangebyscore', KEYS[3], '-inf', now)
+for _, v in ipairs(stale) do
+    redis.call('zrem', KEYS[2], v)
+    redis.call('zrem', KEYS[3], v)
+end
+
+local owner = redis.call('get', KEYS[1])
+-- 同一次获取的重试（上一次超时但实际成功），不是重入
+if owner == value then
+    redis.call('pexpire', KEYS[1], ARGV[2])
+    -- 计数器不存在时（被淘汰或者锁在计数器引入之前写入）重新分配
+    local token = tonumber(redis.call('get', KEYS[4]))
+    if token == nil then
+        token = redis.call('incr', KEYS[4])
+    end
+    return token
+end
+
+-- 锁空闲并且轮到自己（队列为空或者自己在队首）时获取锁
+local head = redis.call('zrange', KEYS[2], 0, 0)[1]
+if owner == false and (head == nil or head == value) then
+    redis.call('set', KEYS[1], value, 'PX', ARGV[2])
+    redis.call('zrem', KEYS[2], value)
+    redis.call('zrem', KEYS[3], value)
+    return redis.call('incr', KEYS[4])
+end
+
+-- 入队，已经在队列中时保持原来的位置
+redis.call('zadd', KEYS[2], 'NX', now, value)
+redis.call('zadd', KEYS[3], now + waitTTL, value)
+redis.call('pexpire', KEYS[2], waitTTL)
+redis.call('pexpire', KEYS[3], waitTTL)
+return 0
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- KEYS[1] 锁，KEYS[2] 释放通知的频道
+if redis.call('get', KEYS[1]) == ARGV[1] then
+    redis.call('del', KEYS[1])
+    redis.call('publish', KEYS[2], ARGV[1])
+    return 1
+else
+    return 0
+end
\ No newline at end of file
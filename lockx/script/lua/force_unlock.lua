@@ -0,0 +1,9 @@
+-- KEYS[1] 锁，KEYS[2] 公平锁释放通知的频道，KEYS[3] 获取时间
+-- 不检查持有者，直接删除锁并唤醒公平锁的等待者
+redis.call('del', KEYS[3])
+if redis.call('del', KEYS[1]) == 1 then
+    redis.call('publish', KEYS[2], '')
+    return 1
+else
+    return 0
+end
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- KEYS[1] 锁，KEYS[2] 获取时间
+-- 返回 {锁的值, 剩余过期时间（毫秒）, 获取时间（毫秒，没有记录时为 nil）}，锁不存在时返回 false
+-- 支持 Lock 获取的普通锁和 ReentrantLock 获取的可重入锁
+local t = redis.call('type', KEYS[1]).ok
+if t == 'none' then
+    return false
+elseif t == 'string' then
+    return {redis.call('get', KEYS[1]), redis.call('pttl', KEYS[1]), redis.call('get', KEYS[2])}
+elseif t == 'hash' then
+    local owner = redis.call('hget', KEYS[1], 'owner')
+    if owner then
+        return {owner, redis.call('pttl', KEYS[1])}
+    end
+end
+return redis.error_reply('unsupported lock type')
\ No newline at end of file
@@ -1,9 +1,22 @@
+-- KEYS[1] 锁，KEYS[2] fencing token 计数器，KEYS[3] 获取时间
+-- ARGV[1] 持有者，ARGV[2] 过期时间（毫秒），ARGV[3] 当前时间（毫秒）
+-- 成功时返回 fencing token，失败时返回 0
 local val = redis.call('get', KEYS[1])
 if val == false then
-    return redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2])
+    redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2])
+    redis.call('set', KEYS[3], ARGV[3], 'PX', ARGV[2])
+    return redis.call('incr', KEYS[2])
 elseif val == ARGV[1] then
-    redis.call('expire', KEYS[1], ARGV[2])
-    return "OK"
+    redis.call('pexpire', KEYS[1], ARGV[2])
+    -- 重入时保留第一次获取的时间
+    redis.call('pexpire', KEYS[3], ARGV[2])
+    -- 持有锁期间计数器不会变化，当前值就是持有者的 token。
+    -- 计数器不存在时（被淘汰或者锁在计数器引入之前写入）重新分配
+    local token = tonumber(redis.call('get', KEYS[2]))
+    if token == nil then
+        token = redis.call('incr', KEYS[2])
+    end
+    return token
 else
-    return ""
+    return 0
 end
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- KEYS[1] 锁，KEYS[2] fencing token 计数器，成功时返回 fencing token，失败时返回 0
+local owner = redis.call('hget', KEYS[1], 'owner')
+if owner == false then
+    local token = redis.call('incr', KEYS[2])
+    redis.call('hset', KEYS[1], 'owner', ARGV[1], 'count', 1, 'token', token)
+    redis.call('pexpire', KEYS[1], ARGV[2])
+    return token
+elseif owner == ARGV[1] then
+    -- 同一个持有者重入，持有次数加一
+    redis.call('hincrby', KEYS[1], 'count', 1)
+    redis.call('pexpire', KEYS[1], ARGV[2])
+    -- 锁在 fencing token 引入之前写入时没有 token，重新分配
+    local token = tonumber(redis.call('hget', KEYS[1], 'token'))
+    if token == nil then
+        token = redis.call('incr', KEYS[2])
+        redis.call('hset', KEYS[1], 'token', token)
+    end
+    return token
+else
+    return 0
+end
\ No newline at end of file
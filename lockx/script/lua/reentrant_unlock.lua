@@ -0,0 +1,10 @@
+if redis.call('hget', KEYS[1], 'owner') ~= ARGV[1] then
+    return -1
+end
+-- 持有次数减到 0 时才真正释放锁
+local count = redis.call('hincrby', KEYS[1], 'count', -1)
+if count <= 0 then
+    redis.call('del', KEYS[1])
+    return 0
+end
+return count
\ No newline at end of file
@@ -1,6 +1,10 @@
+-- KEYS[1] 锁，KEYS[2] 获取时间（可选，Redlock 不记录）
 if redis.call("get", KEYS[1]) == ARGV[1]
 then
-    return redis.call("expire", KEYS[1], ARGV[2])
+    if KEYS[2] then
+        redis.call("pexpire", KEYS[2], ARGV[2])
+    end
+    return redis.call("pexpire", KEYS[1], ARGV[2])
 else
     return 0
 end
\ No newline at end of file
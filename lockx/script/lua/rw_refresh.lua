@@ -0,0 +1,11 @@
+local ttl = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local expireAt = redis.call('hget', KEYS[1], ARGV[1])
+if expireAt == false or tonumber(expireAt) <= now then
+    return 0
+end
+redis.call('hset', KEYS[1], ARGV[1], now + ttl)
+if redis.call('pttl', KEYS[1]) < ttl then
+    redis.call('pexpire', KEYS[1], ttl)
+end
+return 1
\ No newline at end of file
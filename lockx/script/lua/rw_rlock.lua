@@ -0,0 +1,28 @@
+-- hash 字段：r:<holder> 读者，w:<holder> 写者，q:<holder> 等待中的写者，字段值为过期时间（毫秒）
+-- holder 为每次获取各自的持有者
+local key = KEYS[1]
+local ttl = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local blocked = false
+local fields = redis.call('hgetall', key)
+for i = 1, #fields, 2 do
+    if tonumber(fields[i + 1]) <= now then
+        redis.call('hdel', key, fields[i])
+    else
+        local kind = string.sub(fields[i], 1, 2)
+        -- 写者优先：写锁被持有或者有写者在等待时，不能获取读锁
+        if kind == 'w:' or kind == 'q:' then
+            blocked = true
+        end
+    end
+end
+if blocked then
+    return 0
+end
+
+redis.call('hset', key, 'r:' .. ARGV[1], now + ttl)
+if redis.call('pttl', key) < ttl then
+    redis.call('pexpire', key, ttl)
+end
+return 1
\ No newline at end of file
@@ -0,0 +1,7 @@
+if redis.call('hdel', KEYS[1], ARGV[1]) == 0 then
+    return 0
+end
+if redis.call('hlen', KEYS[1]) == 0 then
+    redis.call('del', KEYS[1])
+end
+return 1
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- KEYS[1] 持有者集合，member 为持有者，score 为过期时间（毫秒）
+-- ARGV[1] 持有者，ARGV[2] 许可数，ARGV[3] 过期时间（毫秒），ARGV[4] 当前时间（毫秒）
+local permits = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+
+-- 清理过期的持有者
+redis.call('zremrangebyscore', KEYS[1], '-inf', now)
+if redis.call('zscore', KEYS[1], ARGV[1]) == false and redis.call('zcard', KEYS[1]) >= permits then
+    return 0
+end
+
+redis.call('zadd', KEYS[1], now + ttl, ARGV[1])
+if redis.call('pttl', KEYS[1]) < ttl then
+    redis.call('pexpire', KEYS[1], ttl)
+end
+return 1
\ No newline at end of file
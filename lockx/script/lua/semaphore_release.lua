@@ -0,0 +1,10 @@
+local expireAt = redis.call('zscore', KEYS[1], ARGV[1])
+if expireAt == false then
+    return 0
+end
+redis.call('zrem', KEYS[1], ARGV[1])
+-- 已经过期的许可可能被其他持有者占用，不算作释放成功
+if tonumber(expireAt) <= tonumber(ARGV[2]) then
+    return 0
+end
+return 1
\ No newline at end of file
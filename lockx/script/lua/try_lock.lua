@@ -0,0 +1,8 @@
+-- KEYS[1] 锁，KEYS[2] fencing token 计数器，KEYS[3] 获取时间
+-- ARGV[1] 持有者，ARGV[2] 过期时间（毫秒），ARGV[3] 当前时间（毫秒）
+-- 只在 key 不存在时获取锁（SETNX 语义，相同的持有者也不能重入），成功时返回 fencing token，失败时返回 0
+if redis.call('set', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+    redis.call('set', KEYS[3], ARGV[3], 'PX', ARGV[2])
+    return redis.call('incr', KEYS[2])
+end
+return 0
\ No newline at end of file
@@ -1,5 +1,9 @@
+-- KEYS[1] 锁，KEYS[2] 获取时间（可选，Redlock 不记录）
 if redis.call("get", KEYS[1]) == ARGV[1]
 then
+    if KEYS[2] then
+        redis.call("del", KEYS[2])
+    end
     return redis.call("del", KEYS[1])
 else
     return 0
@@ -0,0 +1,91 @@
+package lockx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/option"
+	"time"
+)
+
+var (
+	//go:embed script/lua/semaphore_acquire.lua
+	luaSemaphoreAcquire string
+	//go:embed script/lua/semaphore_refresh.lua
+	luaSemaphoreRefresh string
+	//go:embed script/lua/semaphore_release.lua
+	luaSemaphoreRelease string
+)
+
+// Semaphore 分布式信号量，同一个 key 最多允许 permits 个持有者同时持有许可。
+// 持有者保存在 redis 有序集合中，score 为许可的过期时间，崩溃的持有者过期后自动被清理。
+// 各节点的时钟偏差应远小于过期时间。
+type Semaphore struct {
+	permits int
+	// cfg 复用 Client 的配置：expiration、timeout 和 retry
+	cfg *Client
+}
+
+// NewSemaphore 创建 Semaphore 实例。
+// permits: 每个 key 的许可数，必须大于 0。
+// opts: 与 NewClient 的选项相同。
+func NewSemaphore(client redis.Cmdable, permits int, opts ...option.Option[Client]) *Semaphore {
+	if permits <= 0 {
+		panic("permits must be greater than 0")
+	}
+	return &Semaphore{permits: permits, cfg: NewClient(client, opts...)}
+}
+
+// Permit 信号量许可，与 Lock 一样支持 Refresh 和 AutoRefresh
+type Permit struct {
+	*Lock
+}
+
+// Release 释放许可
+func (p *Permit) Release(ctx context.Context) error {
+	return p.Unlock(ctx)
+}
+
+// Acquire 获取许可，失败时按照重试策略重试。
+// 每次获取都使用新的持有者标识，同一个 Client 多次获取会占用多个许可。
+func (s *Semaphore) Acquire(ctx context.Context, key string) (*Permit, error) {
+	holder := s.cfg.value + ":" + uuid.New().String()
+	err := s.cfg.acquire(ctx, func(ctx context.Context) (bool, error) {
+		res, err := s.cfg.client.Eval(ctx, luaSemaphoreAcquire, []string{key},
+			holder, s.permits, s.cfg.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+		return res == 1, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lock := newLock(s.cfg.client, key, holder, s.cfg.expiration, s.cfg.timeout)
+	lock.semaphore = true
+	return &Permit{Lock: lock}, nil
+}
+
+// semaphoreRelease 释放许可
+func (l *Lock) semaphoreRelease(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaSemaphoreRelease, []string{l.key}, l.value, time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
+
+// semaphoreRefresh 刷新许可的过期时间
+func (l *Lock) semaphoreRefresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaSemaphoreRefresh, []string{l.key},
+		l.value, l.expiration.Milliseconds(), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotHoldingLock
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package lockx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	sem := NewSemaphore(rdb, 2, WithExpiration(time.Second))
+	p1, err := sem.Acquire(ctx, "export")
+	require.NoError(t, err)
+	p2, err := sem.Acquire(ctx, "export")
+	require.NoError(t, err)
+
+	// 许可用完后获取失败
+	_, err = sem.Acquire(ctx, "export")
+	assert.ErrorIs(t, err, ErrGetLockFailed)
+
+	require.NoError(t, p1.Refresh(ctx))
+	require.NoError(t, p1.Release(ctx))
+	assert.ErrorIs(t, p1.Release(ctx), ErrNotHoldingLock)
+	assert.ErrorIs(t, p1.Refresh(ctx), ErrNotHoldingLock)
+
+	p3, err := sem.Acquire(ctx, "export")
+	require.NoError(t, err)
+	require.NoError(t, p2.Release(ctx))
+	require.NoError(t, p3.Release(ctx))
+	assert.False(t, s.Exists("export"))
+}
+
+func TestSemaphoreStaleHolder(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	crashed := NewSemaphore(rdb, 1, WithExpiration(50*time.Millisecond))
+	_, err := crashed.Acquire(ctx, "export")
+	require.NoError(t, err)
+
+	// 崩溃的持有者过期后被清理，等待中的持有者按照重试策略获取到许可
+	sem := NewSemaphore(rdb, 1, WithRetry(kit.NewFixIntervalRetry(20*time.Millisecond, 10)))
+	start := time.Now()
+	p, err := sem.Acquire(ctx, "export")
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+	members, err := s.ZMembers("export")
+	require.NoError(t, err)
+	assert.Equal(t, []string{p.value}, members)
+}
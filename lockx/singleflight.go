@@ -0,0 +1,207 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/cache"
+	"github.com/shijting/kit/option"
+	"github.com/shijting/kit/syncx"
+	"time"
+)
+
+// Singleflight 分布式 singleflight，用于防止热点 key 过期后大量请求同时回源。
+// 进程内相同 key 的并发调用先合并为一次，然后各个进程竞争分布式锁，
+// 抢到锁的进程执行 loader 并把结果写入共享缓存，其他进程通过发布订阅或者轮询缓存等待结果。
+// 多个进程之间共享结果需要使用共享缓存，例如 redis 缓存。
+type Singleflight[V any] struct {
+	client *Client
+	cache  cache.Cache[string, V]
+	group  syncx.Group[string, V]
+	// expiration 加载结果在缓存中的过期时间，0 表示永不过期
+	expiration time.Duration
+	// pollInterval 等待其他进程加载时轮询缓存的间隔
+	pollInterval time.Duration
+	// loadTimeout 一次合并加载的超时时间，包括等待其他进程加载的时间
+	loadTimeout time.Duration
+}
+
+// NewSingleflight 创建 Singleflight 实例。
+// client: 用于获取分布式锁，锁的过期时间应大于 loader 的执行时间，执行期间会自动续约。
+// c: 保存加载结果的缓存。
+// 加载超时时间默认为 client 的锁过期时间。
+func NewSingleflight[V any](client *Client, c cache.Cache[string, V], opts ...option.Option[Singleflight[V]]) *Singleflight[V] {
+	s := &Singleflight[V]{
+		client:       client,
+		cache:        c,
+		pollInterval: 100 * time.Millisecond,
+		loadTimeout:  client.expiration,
+	}
+	option.Options[Singleflight[V]](opts).Apply(s)
+	return s
+}
+
+// WithLoadExpiration 设置加载结果在缓存中的过期时间
+func WithLoadExpiration[V any](expiration time.Duration) option.Option[Singleflight[V]] {
+	return func(s *Singleflight[V]) {
+		s.expiration = expiration
+	}
+}
+
+// WithPollInterval 设置等待其他进程加载时轮询缓存的间隔
+func WithPollInterval[V any](interval time.Duration) option.Option[Singleflight[V]] {
+	return func(s *Singleflight[V]) {
+		s.pollInterval = interval
+	}
+}
+
+// WithLoadTimeout 设置一次合并加载的超时时间，包括等待其他进程加载的时间。
+// 超时后 loader 的 ctx 被取消，loader 不响应 ctx 时不再等待它返回，后续的调用重新加载。
+func WithLoadTimeout[V any](timeout time.Duration) option.Option[Singleflight[V]] {
+	return func(s *Singleflight[V]) {
+		s.loadTimeout = timeout
+	}
+}
+
+// Do 从缓存中获取 key 对应的值，缓存未命中时保证同一时刻只有一个进程执行 loader。
+// 加载失败时不写入缓存，等待中的进程会重新竞争锁并加载。
+// 进程内合并的加载使用不会随第一个调用方取消的 context，只受加载超时时间限制，
+// 每个调用方只在自己的 ctx 结束时提前返回。
+func (s *Singleflight[V]) Do(ctx context.Context, key string, loader func(ctx context.Context) (V, error)) (V, error) {
+	ch := s.group.DoChan(key, func() (V, error) {
+		lctx, cancel := context.WithTimeout(withoutCancel(ctx), s.loadTimeout)
+		defer cancel()
+		return s.load(lctx, key, loader)
+	})
+	select {
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	case res := <-ch:
+		return res.Val, res.Err
+	}
+}
+
+// withoutCancel 返回保留 ctx 中的值但是不会被取消、没有截止时间的 context
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
+
+func (s *Singleflight[V]) load(ctx context.Context, key string, loader func(ctx context.Context) (V, error)) (V, error) {
+	var zero V
+	if item, ok := s.cache.Get(ctx, key); ok {
+		return item.Value, nil
+	}
+
+	lockKey, channel := key+":loading", key+":loaded"
+	var loaded <-chan *redis.Message
+	// 非 redis 后端只能轮询等待
+	if sub, ok := s.client.client.(subscriber); ok {
+		pubsub := sub.Subscribe(ctx, channel)
+		defer pubsub.Close()
+		if _, err := pubsub.Receive(ctx); err != nil {
+			return zero, err
+		}
+		loaded = pubsub.Channel()
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		lock, err := s.client.TryLock(ctx, lockKey, s.client.expiration, s.client.timeout)
+		if err == nil {
+			return s.loadWithLock(ctx, key, channel, lock, loader)
+		}
+		if !errors.Is(err, ErrGetLockFailed) {
+			return zero, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-loaded:
+		case <-ticker.C:
+		}
+		if item, ok := s.cache.Get(ctx, key); ok {
+			return item.Value, nil
+		}
+	}
+}
+
+func (s *Singleflight[V]) loadWithLock(ctx context.Context, key, channel string, lock *Lock, loader func(ctx context.Context) (V, error)) (V, error) {
+	var v V
+	err := s.client.holdLock(ctx, lock, func(ctx context.Context) error {
+		// 其他进程可能在抢到锁之前已经加载完成
+		if item, ok := s.cache.Get(ctx, key); ok {
+			v = item.Value
+			return nil
+		}
+
+		val, err := s.callLoader(ctx, loader)
+		if err != nil {
+			return err
+		}
+		if err = s.cache.Set(ctx, key, val, s.expiration); err != nil {
+			return err
+		}
+		v = val
+		// 通知失败时等待中的进程会通过轮询拿到结果
+		if s.client.client != nil {
+			s.client.client.Publish(ctx, channel, lock.value)
+		}
+		return nil
+	})
+	return v, err
+}
+
+// callLoader 调用 loader，ctx 结束时不再等待不响应 ctx 的 loader，返回 ctx.Err() 以便释放锁。
+// loader panic 时在调用方的 goroutine 中重新 panic。
+func (s *Singleflight[V]) callLoader(ctx context.Context, loader func(ctx context.Context) (V, error)) (V, error) {
+	type result struct {
+		val       V
+		err       error
+		recovered any
+	}
+	ch := make(chan result, 1)
+	go func() {
+		var res result
+		defer func() {
+			if r := recover(); r != nil {
+				res.recovered = r
+			}
+			ch <- res
+		}()
+		res.val, res.err = loader(ctx)
+	}()
+
+	select {
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.recovered != nil {
+			panic(res.recovered)
+		}
+		return res.val, res.err
+	}
+}
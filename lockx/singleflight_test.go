@@ -0,0 +1,132 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shijting/kit/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflight(t *testing.T) {
+	s := miniredis.RunT(t)
+	// 用同一个 LRUCache 模拟多个进程共享的缓存
+	shared := cache.NewLRUCache[string, string](10)
+
+	var loads int32
+	loader := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(100 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for pod := 0; pod < 3; pod++ {
+		rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+		sf := NewSingleflight[string](NewClient(rdb), shared, WithPollInterval[string](time.Second))
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := sf.Do(context.Background(), "hot", loader)
+				assert.NoError(t, err)
+				assert.Equal(t, "value", v)
+			}()
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), loads)
+	assert.False(t, s.Exists("hot:loading"))
+}
+
+func TestSingleflightLoadError(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	sf := NewSingleflight[int](NewClient(rdb), cache.NewLRUCache[string, int](10), WithLoadExpiration[int](time.Minute))
+
+	errLoad := errors.New("load error")
+	_, err := sf.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+		return 0, errLoad
+	})
+	assert.ErrorIs(t, err, errLoad)
+
+	// 加载失败不写入缓存，下一次调用重新加载
+	v, err := sf.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	v, err = sf.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestSingleflight_CancelFirstCaller(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	sf := NewSingleflight[string](NewClient(rdb), cache.NewLRUCache[string, string](10))
+
+	started := make(chan struct{})
+	loader := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return "value", nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := sf.Do(ctx, "hot", loader)
+		first <- err
+	}()
+	<-started
+	second := make(chan string, 1)
+	go func() {
+		v, err := sf.Do(context.Background(), "hot", loader)
+		assert.NoError(t, err)
+		second <- v
+	}()
+
+	// 第一个调用方取消后立即返回，合并的加载不受影响
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	assert.Equal(t, "value", <-second)
+}
+
+func TestSingleflight_LoadTimeout(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	sf := NewSingleflight[string](NewClient(rdb), cache.NewLRUCache[string, string](10),
+		WithLoadTimeout[string](50*time.Millisecond))
+
+	// loader 不响应 ctx 一直阻塞，合并的加载在超时后结束
+	hang := make(chan struct{})
+	defer close(hang)
+	_, err := sf.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		<-hang
+		return "stale", nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 后续的调用重新加载，不会一直等待卡住的 loader
+	v, err := sf.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		return "value", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "value", v)
+	assert.False(t, s.Exists("key:loading"))
+}
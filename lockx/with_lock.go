@@ -0,0 +1,59 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrLockLost 持有锁期间续约失败或者锁已经不属于自己
+var ErrLockLost = errors.New("lock lost")
+
+// WithLock 获取锁后执行 fn，执行期间在后台自动续约，返回前总会释放锁。
+// 续约失败或者锁被其他持有者获取时，fn 的 ctx 会被取消，WithLock 返回 ErrLockLost。
+// 续约间隔为过期时间的 1/3，超时的续约最多重试 3 次。
+func (cli *Client) WithLock(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	lock, err := cli.Lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	return cli.holdLock(ctx, lock, fn)
+}
+
+// holdLock 持有 lock 执行 fn，执行期间自动续约，返回前释放锁
+func (cli *Client) holdLock(ctx context.Context, lock *Lock, fn func(ctx context.Context) error) error {
+	fctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := make(chan struct{})
+	errCh := lock.AutoRefresh(cli.expiration/3, stop, 3)
+	var refreshErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err, ok := <-errCh; ok {
+			refreshErr = err
+			cancel()
+		}
+	}()
+
+	fnErr := fn(fctx)
+	close(stop)
+	<-done
+
+	// ctx 可能已经被取消，使用新的 ctx 释放锁
+	uctx, ucancel := context.WithTimeout(context.Background(), cli.timeout)
+	unlockErr := lock.Unlock(uctx)
+	ucancel()
+
+	if refreshErr != nil {
+		return fmt.Errorf("%w: %v", ErrLockLost, refreshErr)
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if errors.Is(unlockErr, ErrNotHoldingLock) {
+		return fmt.Errorf("%w: %v", ErrLockLost, unlockErr)
+	}
+	return unlockErr
+}
@@ -0,0 +1,89 @@
+package lockx
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClient_WithLock(t *testing.T) {
+	errBiz := errors.New("biz error")
+	testCases := []struct {
+		name    string
+		fn      func(s *miniredis.Miniredis) func(ctx context.Context) error
+		wantErr error
+	}{
+		{
+			name: "success",
+			fn: func(s *miniredis.Miniredis) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					// 执行时间超过过期时间，依赖自动续约
+					time.Sleep(400 * time.Millisecond)
+					if !s.Exists("key") {
+						return errors.New("lock expired")
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name: "callback error",
+			fn: func(s *miniredis.Miniredis) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return errBiz
+				}
+			},
+			wantErr: errBiz,
+		},
+		{
+			name: "lock lost",
+			fn: func(s *miniredis.Miniredis) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					// 锁被其他持有者抢走，续约失败后 ctx 被取消
+					require.NoError(t, s.Set("key", "other"))
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(time.Second):
+						return errors.New("ctx not canceled")
+					}
+				}
+			},
+			wantErr: ErrLockLost,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+			// miniredis 的过期时间只在 FastForward 时生效，这里用真实时间驱动过期
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() {
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						s.FastForward(10 * time.Millisecond)
+					}
+				}
+			}()
+
+			cli := NewClient(rdb, WithValue("me"), WithExpiration(150*time.Millisecond))
+			err := cli.WithLock(context.Background(), "key", tc.fn(s))
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != ErrLockLost {
+				assert.False(t, s.Exists("key"))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package netx
+
+import "sync"
+
+// 常用的会话属性键。
+// 限流状态的类型取决于使用的限流器（例如 limiter.Bucket），netx 不依赖限流器，
+// 由调用方用 NewKey 定义自己的键，例如 NewKey[*limiter.Bucket]("rate")。
+var (
+	// UserIDKey 会话绑定的用户 ID
+	UserIDKey = NewKey[string]("user_id")
+	// ClaimsKey 会话认证后的声明信息
+	ClaimsKey = NewKey[map[string]any]("claims")
+)
+
+// Key 会话属性的键，T 为属性值的类型。
+// 键通过指针区分，同名的两个 Key 互不影响。
+type Key[T any] struct {
+	name string
+}
+
+// NewKey 创建一个属性键，name 仅用于调试输出
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+// Get 获取会话中该键对应的值，不存在时返回零值和 false
+func (k *Key[T]) Get(s *Session) (T, bool) {
+	var zero T
+	v, ok := s.attrs.load(k)
+	if !ok {
+		return zero, false
+	}
+	return v.(T), true
+}
+
+// Set 设置会话中该键对应的值，会话关闭后设置无效
+func (k *Key[T]) Set(s *Session, value T) {
+	s.attrs.store(k, value)
+}
+
+// LoadOrStore 键存在时返回已有的值和 true，否则存入 value 并返回 value 和 false，
+// 会话关闭后不再存入，直接返回 value 和 false
+func (k *Key[T]) LoadOrStore(s *Session, value T) (T, bool) {
+	actual, loaded := s.attrs.loadOrStore(k, value)
+	return actual.(T), loaded
+}
+
+// Delete 删除会话中该键对应的值
+func (k *Key[T]) Delete(s *Session) {
+	s.attrs.delete(k)
+}
+
+// attributes 会话属性存储，会话关闭后清空并且不再接受写入
+type attributes struct {
+	mu   sync.RWMutex
+	data map[any]any
+	// closed clear 之后为 true，关闭后仍在运行的处理函数写入的值被忽略
+	closed bool
+}
+
+func (a *attributes) load(key any) (any, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	v, ok := a.data[key]
+	return v, ok
+}
+
+func (a *attributes) store(key, value any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	if a.data == nil {
+		a.data = make(map[any]any)
+	}
+	a.data[key] = value
+}
+
+func (a *attributes) loadOrStore(key, value any) (any, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if v, ok := a.data[key]; ok {
+		return v, true
+	}
+	if a.closed {
+		return value, false
+	}
+	if a.data == nil {
+		a.data = make(map[any]any)
+	}
+	a.data[key] = value
+	return value, false
+}
+
+func (a *attributes) delete(key any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.data, key)
+}
+
+func (a *attributes) clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.data = nil
+	a.closed = true
+}
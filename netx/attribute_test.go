@@ -0,0 +1,90 @@
+package netx
+
+import (
+	"github.com/shijting/kit/codex"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	type rateState struct {
+		tokens int
+	}
+	rateKey := NewKey[*rateState]("rate")
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	sess := NewSession(codex.NewJson(conn), conn)
+
+	_, ok := UserIDKey.Get(sess)
+	assert.False(t, ok)
+
+	UserIDKey.Set(sess, "u1")
+	ClaimsKey.Set(sess, map[string]any{"role": "admin"})
+	userID, ok := UserIDKey.Get(sess)
+	assert.True(t, ok)
+	assert.Equal(t, "u1", userID)
+	claims, _ := ClaimsKey.Get(sess)
+	assert.Equal(t, "admin", claims["role"])
+
+	// 同名的不同键互不影响
+	other := NewKey[string]("user_id")
+	_, ok = other.Get(sess)
+	assert.False(t, ok)
+
+	state, loaded := rateKey.LoadOrStore(sess, &rateState{tokens: 10})
+	assert.False(t, loaded)
+	actual, loaded := rateKey.LoadOrStore(sess, &rateState{tokens: 1})
+	assert.True(t, loaded)
+	assert.Same(t, state, actual)
+
+	UserIDKey.Delete(sess)
+	_, ok = UserIDKey.Get(sess)
+	assert.False(t, ok)
+
+	// 关闭回调中仍可读取属性，关闭后属性被清空
+	var inCallback bool
+	sess.AddCloseCallback(func(s *Session) {
+		_, inCallback = rateKey.Get(s)
+	})
+	_ = sess.Close()
+	assert.True(t, inCallback)
+	_, ok = rateKey.Get(sess)
+	assert.False(t, ok)
+
+	// 关闭后仍在运行的处理函数写入的值被忽略
+	UserIDKey.Set(sess, "u2")
+	_, ok = UserIDKey.Get(sess)
+	assert.False(t, ok)
+	state, loaded = rateKey.LoadOrStore(sess, &rateState{tokens: 5})
+	assert.False(t, loaded)
+	assert.Equal(t, 5, state.tokens)
+	_, ok = rateKey.Get(sess)
+	assert.False(t, ok)
+}
+
+func TestKeyConcurrent(t *testing.T) {
+	counterKey := NewKey[int]("counter")
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	sess := NewSession(codex.NewJson(conn), conn)
+	defer sess.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counterKey.Set(sess, i)
+			_, _ = counterKey.Get(sess)
+			_, _ = counterKey.LoadOrStore(sess, i)
+		}(i)
+	}
+	wg.Wait()
+
+	_, ok := counterKey.Get(sess)
+	assert.True(t, ok)
+}
@@ -1,21 +1,41 @@
 package netx
 
 import (
+	"errors"
 	"github.com/shijting/kit/codex"
 	"github.com/shijting/kit/option"
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
 const sessionMapSize uint64 = 32
 
+var (
+	ErrManagerClosed   = errors.New("manager closed")
+	ErrTooManySessions = errors.New("too many sessions")
+)
+
 type Manager struct {
 	sessionMaps map[uint64]*sessionMap
 	closeOnce   sync.Once
+	closed      bool
+	closedMu    sync.RWMutex
+
+	// maxSessions 最大会话数，0 表示不限制
+	maxSessions int64
+	stats       *statsMetrics
+	// metrics 同时上报给内部统计和用户设置的 Metrics
+	metrics Metrics
 }
 
-func NewManager() *Manager {
-	m := new(Manager)
+func NewManager(opts ...option.Option[Manager]) *Manager {
+	m := &Manager{
+		sessionMaps: make(map[uint64]*sessionMap, sessionMapSize),
+		stats:       &statsMetrics{},
+	}
+	m.metrics = m.stats
+	option.Options[Manager](opts).Apply(m)
 
 	for i := uint64(0); i < sessionMapSize; i++ {
 		m.sessionMaps[i] = &sessionMap{sessions: make(map[uint64]*Session)}
@@ -23,14 +43,29 @@ func NewManager() *Manager {
 	return m
 }
 
+// WithMetrics 设置指标上报，Stats 的统计不受影响
+func WithMetrics(metrics Metrics) option.Option[Manager] {
+	return func(m *Manager) {
+		m.metrics = multiMetrics{m.stats, metrics}
+	}
+}
+
+// WithMaxSessions 设置最大会话数，超过后 Accept 拒绝新连接
+func WithMaxSessions(max int) option.Option[Manager] {
+	return func(m *Manager) {
+		m.maxSessions = int64(max)
+	}
+}
+
 type sessionMap struct {
 	sessions map[uint64]*Session
 	sync.RWMutex
 	isClosed bool
 }
 
+// NewSession 创建会话并交由 Manager 管理，不检查最大会话数。
 func (m *Manager) NewSession(conn net.Conn, code codex.Codex, sendSize int) *Session {
-	opts := make([]option.Option[Session], 0)
+	opts := []option.Option[Session]{WithSessionMetrics(m.metrics)}
 	if sendSize > 0 {
 		opts = append(opts, WithSendSize(sendSize))
 	}
@@ -40,11 +75,43 @@ func (m *Manager) NewSession(conn net.Conn, code codex.Codex, sendSize int) *Ses
 	return sess
 }
 
+// Accept 接受一个新连接并创建会话。
+// Manager 已关闭或会话数达到上限时关闭连接，并返回 ErrManagerClosed 或 ErrTooManySessions。
+func (m *Manager) Accept(conn net.Conn, code codex.Codex, sendSize int) (*Session, error) {
+	m.closedMu.RLock()
+	closed := m.closed
+	m.closedMu.RUnlock()
+
+	var err error
+	switch {
+	case closed:
+		m.metrics.ConnRejected(RejectReasonManagerClosed)
+		err = ErrManagerClosed
+	case m.maxSessions > 0 && atomic.LoadInt64(&m.stats.activeSessions) >= m.maxSessions:
+		m.metrics.ConnRejected(RejectReasonTooManySessions)
+		err = ErrTooManySessions
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	m.metrics.ConnAccepted()
+	return m.NewSession(conn, code, sendSize), nil
+}
+
+// Stats 返回 Manager 的统计快照
+func (m *Manager) Stats() Stats {
+	return m.stats.snapshot()
+}
+
 func (m *Manager) putSession(sess *Session) {
 	sessMap := m.sessionMaps[sess.id%sessionMapSize]
 	sessMap.Lock()
 	defer sessMap.Unlock()
 	sessMap.sessions[sess.id] = sess
+	// 会话关闭后从 Manager 中移除
+	sess.AddCloseCallback(m.DelSession)
 	return
 }
 
@@ -70,14 +137,23 @@ func (m *Manager) delSessionById(sessMap *sessionMap, sessionId uint64) {
 
 func (m *Manager) Close() {
 	m.closeOnce.Do(func() {
+		m.closedMu.Lock()
+		m.closed = true
+		m.closedMu.Unlock()
+
 		for _, sessMap := range m.sessionMaps {
 			sessMap.Lock()
 			sessMap.isClosed = true
+			sessions := make([]*Session, 0, len(sessMap.sessions))
 			for _, sess := range sessMap.sessions {
-				_ = sess.Close()
-				m.delSessionById(sessMap, sess.id)
+				sessions = append(sessions, sess)
 			}
 			sessMap.Unlock()
+
+			// 关闭回调会调用 DelSession，不能在持有锁时关闭会话
+			for _, sess := range sessions {
+				sess.closeWithReason(CloseReasonManagerClosed)
+			}
 		}
 	})
 }
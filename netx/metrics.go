@@ -0,0 +1,303 @@
+package netx
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	_ Metrics = NopMetrics{}
+	_ Metrics = (*statsMetrics)(nil)
+	_ Metrics = multiMetrics(nil)
+)
+
+// CloseReason 会话关闭原因
+type CloseReason string
+
+const (
+	// CloseReasonNormal 调用了 Session.Close
+	CloseReasonNormal CloseReason = "normal"
+	// CloseReasonPeerClosed 对端关闭了连接
+	CloseReasonPeerClosed CloseReason = "peer_closed"
+	// CloseReasonReadError 读取数据失败
+	CloseReasonReadError CloseReason = "read_error"
+	// CloseReasonWriteError 写出数据失败
+	CloseReasonWriteError CloseReason = "write_error"
+	// CloseReasonCodecError 编解码失败
+	CloseReasonCodecError CloseReason = "codec_error"
+	// CloseReasonManagerClosed 所属的 Manager 被关闭
+	CloseReasonManagerClosed CloseReason = "manager_closed"
+)
+
+// 连接被拒绝的原因
+const (
+	RejectReasonManagerClosed   = "manager_closed"
+	RejectReasonTooManySessions = "too_many_sessions"
+	RejectReasonUpgradeFailed   = "upgrade_failed"
+)
+
+// Metrics 网络指标上报接口。
+// 实现方需要保证并发安全，且不应阻塞调用方，例如可以由 Prometheus 适配器实现。
+type Metrics interface {
+	// ConnAccepted 接受了一个连接
+	ConnAccepted()
+	// ConnRejected 拒绝了一个连接
+	ConnRejected(reason string)
+	// SessionOpened 创建了一个会话
+	SessionOpened(sess *Session)
+	// SessionClosed 会话被关闭
+	SessionClosed(sess *Session, reason CloseReason)
+	// BytesReceived 会话读取了 n 字节
+	BytesReceived(sess *Session, n int)
+	// BytesSent 会话写出了 n 字节
+	BytesSent(sess *Session, n int)
+	// MessageReceived 会话接收了一条消息
+	MessageReceived(sess *Session)
+	// MessageSent 会话发送了一条消息
+	MessageSent(sess *Session)
+	// SendQueueDepth 会话发送队列的当前长度
+	SendQueueDepth(sess *Session, depth int)
+	// CodecError 会话编解码失败
+	CodecError(sess *Session, err error)
+}
+
+// NopMetrics 不做任何上报
+type NopMetrics struct{}
+
+func (NopMetrics) ConnAccepted()                       {}
+func (NopMetrics) ConnRejected(string)                 {}
+func (NopMetrics) SessionOpened(*Session)              {}
+func (NopMetrics) SessionClosed(*Session, CloseReason) {}
+func (NopMetrics) BytesReceived(*Session, int)         {}
+func (NopMetrics) BytesSent(*Session, int)             {}
+func (NopMetrics) MessageReceived(*Session)            {}
+func (NopMetrics) MessageSent(*Session)                {}
+func (NopMetrics) SendQueueDepth(*Session, int)        {}
+func (NopMetrics) CodecError(*Session, error)          {}
+
+// multiMetrics 将指标同时上报给多个 Metrics
+type multiMetrics []Metrics
+
+func (ms multiMetrics) ConnAccepted() {
+	for _, m := range ms {
+		m.ConnAccepted()
+	}
+}
+
+func (ms multiMetrics) ConnRejected(reason string) {
+	for _, m := range ms {
+		m.ConnRejected(reason)
+	}
+}
+
+func (ms multiMetrics) SessionOpened(sess *Session) {
+	for _, m := range ms {
+		m.SessionOpened(sess)
+	}
+}
+
+func (ms multiMetrics) SessionClosed(sess *Session, reason CloseReason) {
+	for _, m := range ms {
+		m.SessionClosed(sess, reason)
+	}
+}
+
+func (ms multiMetrics) BytesReceived(sess *Session, n int) {
+	for _, m := range ms {
+		m.BytesReceived(sess, n)
+	}
+}
+
+func (ms multiMetrics) BytesSent(sess *Session, n int) {
+	for _, m := range ms {
+		m.BytesSent(sess, n)
+	}
+}
+
+func (ms multiMetrics) MessageReceived(sess *Session) {
+	for _, m := range ms {
+		m.MessageReceived(sess)
+	}
+}
+
+func (ms multiMetrics) MessageSent(sess *Session) {
+	for _, m := range ms {
+		m.MessageSent(sess)
+	}
+}
+
+func (ms multiMetrics) SendQueueDepth(sess *Session, depth int) {
+	for _, m := range ms {
+		m.SendQueueDepth(sess, depth)
+	}
+}
+
+func (ms multiMetrics) CodecError(sess *Session, err error) {
+	for _, m := range ms {
+		m.CodecError(sess, err)
+	}
+}
+
+// Stats Manager 的统计快照
+type Stats struct {
+	// ActiveSessions 当前活跃的会话数
+	ActiveSessions int64
+	// AcceptedConns 累计接受的连接数
+	AcceptedConns uint64
+	// RejectedConns 累计拒绝的连接数
+	RejectedConns uint64
+	// BytesReceived 累计读取的字节数
+	BytesReceived uint64
+	// BytesSent 累计写出的字节数
+	BytesSent uint64
+	// MessagesReceived 累计接收的消息数
+	MessagesReceived uint64
+	// MessagesSent 累计发送的消息数
+	MessagesSent uint64
+	// CodecErrors 累计编解码失败次数
+	CodecErrors uint64
+	// CloseReasons 各关闭原因的累计次数
+	CloseReasons map[CloseReason]uint64
+}
+
+// statsMetrics 在 Manager 内部累计统计数据
+type statsMetrics struct {
+	activeSessions   int64
+	acceptedConns    uint64
+	rejectedConns    uint64
+	bytesReceived    uint64
+	bytesSent        uint64
+	messagesReceived uint64
+	messagesSent     uint64
+	codecErrors      uint64
+
+	mu           sync.Mutex
+	closeReasons map[CloseReason]uint64
+}
+
+func (s *statsMetrics) ConnAccepted() {
+	atomic.AddUint64(&s.acceptedConns, 1)
+}
+
+func (s *statsMetrics) ConnRejected(string) {
+	atomic.AddUint64(&s.rejectedConns, 1)
+}
+
+func (s *statsMetrics) SessionOpened(*Session) {
+	atomic.AddInt64(&s.activeSessions, 1)
+}
+
+func (s *statsMetrics) SessionClosed(_ *Session, reason CloseReason) {
+	atomic.AddInt64(&s.activeSessions, -1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closeReasons == nil {
+		s.closeReasons = make(map[CloseReason]uint64)
+	}
+	s.closeReasons[reason]++
+}
+
+func (s *statsMetrics) BytesReceived(_ *Session, n int) {
+	atomic.AddUint64(&s.bytesReceived, uint64(n))
+}
+
+func (s *statsMetrics) BytesSent(_ *Session, n int) {
+	atomic.AddUint64(&s.bytesSent, uint64(n))
+}
+
+func (s *statsMetrics) MessageReceived(*Session) {
+	atomic.AddUint64(&s.messagesReceived, 1)
+}
+
+func (s *statsMetrics) MessageSent(*Session) {
+	atomic.AddUint64(&s.messagesSent, 1)
+}
+
+func (s *statsMetrics) SendQueueDepth(*Session, int) {}
+
+func (s *statsMetrics) CodecError(*Session, error) {
+	atomic.AddUint64(&s.codecErrors, 1)
+}
+
+func (s *statsMetrics) snapshot() Stats {
+	stats := Stats{
+		ActiveSessions:   atomic.LoadInt64(&s.activeSessions),
+		AcceptedConns:    atomic.LoadUint64(&s.acceptedConns),
+		RejectedConns:    atomic.LoadUint64(&s.rejectedConns),
+		BytesReceived:    atomic.LoadUint64(&s.bytesReceived),
+		BytesSent:        atomic.LoadUint64(&s.bytesSent),
+		MessagesReceived: atomic.LoadUint64(&s.messagesReceived),
+		MessagesSent:     atomic.LoadUint64(&s.messagesSent),
+		CodecErrors:      atomic.LoadUint64(&s.codecErrors),
+		CloseReasons:     make(map[CloseReason]uint64),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for reason, cnt := range s.closeReasons {
+		stats.CloseReasons[reason] = cnt
+	}
+	return stats
+}
+
+// SessionStats 会话的统计快照
+type SessionStats struct {
+	BytesReceived    uint64
+	BytesSent        uint64
+	MessagesReceived uint64
+	MessagesSent     uint64
+	// SendQueueDepth 发送队列的当前长度，未开启发送队列时为 0
+	SendQueueDepth int
+}
+
+// MeteredConn 统计读写字节数的连接。
+// 只有编解码器基于 MeteredConn 创建时，Session 才能统计到字节数。
+type MeteredConn struct {
+	net.Conn
+	onRead  func(n int)
+	onWrite func(n int)
+}
+
+// NewMeteredConn 创建 MeteredConn 实例。
+func NewMeteredConn(conn net.Conn) *MeteredConn {
+	return &MeteredConn{Conn: conn}
+}
+
+// Unwrap 返回被包装的原始连接，例如用于断言为 *net.TCPConn 设置 keep-alive
+func (c *MeteredConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+func (c *MeteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.onRead != nil {
+		c.onRead(n)
+	}
+	return n, err
+}
+
+func (c *MeteredConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 && c.onWrite != nil {
+		c.onWrite(n)
+	}
+	return n, err
+}
+
+// isCodecError 判断错误是否由编解码引起，网络错误和连接关闭不属于编解码错误
+func isCodecError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, websocket.ErrCloseSent) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return false
+	}
+	var ce *websocket.CloseError
+	return !errors.As(err, &ce)
+}
@@ -0,0 +1,148 @@
+package netx
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/shijting/kit/codex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordMetrics struct {
+	NopMetrics
+	mu       sync.Mutex
+	rejected []string
+	closed   []CloseReason
+}
+
+func (r *recordMetrics) ConnRejected(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, reason)
+}
+
+func (r *recordMetrics) SessionClosed(_ *Session, reason CloseReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = append(r.closed, reason)
+}
+
+func TestManagerStats(t *testing.T) {
+	metrics := &recordMetrics{}
+	manager := NewManager(WithMetrics(metrics), WithMaxSessions(1))
+	server := NewWsServer(codex.NewJson, echoHandler(), 0, WithWsManager(manager))
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	sess, err := DialWs(url, codex.NewJson, 0)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, sess.Send(&wsMessage{ID: i}))
+		require.NoError(t, sess.Receive(&wsMessage{}))
+	}
+	assert.Equal(t, uint64(2), sess.Stats().MessagesSent)
+	assert.Equal(t, uint64(2), sess.Stats().MessagesReceived)
+	assert.True(t, sess.Stats().BytesSent > 0)
+
+	// 超过最大会话数，新连接被拒绝
+	rejected, err := DialWs(url, codex.NewJson, 0)
+	require.NoError(t, err)
+	assert.Error(t, rejected.Receive(&wsMessage{}))
+
+	stats := manager.Stats()
+	assert.Equal(t, int64(1), stats.ActiveSessions)
+	assert.Equal(t, uint64(1), stats.AcceptedConns)
+	assert.Equal(t, uint64(1), stats.RejectedConns)
+	assert.Equal(t, uint64(2), stats.MessagesReceived)
+	assert.Equal(t, uint64(2), stats.MessagesSent)
+	assert.True(t, stats.BytesReceived > 0)
+	assert.True(t, stats.BytesSent > 0)
+
+	// 原始 websocket 连接同样受最大会话数限制
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	_ = sess.Close()
+	require.Eventually(t, func() bool {
+		return manager.Stats().ActiveSessions == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// 发送非法数据，服务端解码失败后关闭会话
+	ws, _, err = websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte("{bad json")))
+	require.Eventually(t, func() bool {
+		return manager.Stats().CodecErrors == 1
+	}, time.Second, 10*time.Millisecond)
+
+	manager.Close()
+	stats = manager.Stats()
+	assert.Equal(t, int64(0), stats.ActiveSessions)
+	assert.Equal(t, uint64(1), stats.CloseReasons[CloseReasonCodecError])
+	assert.Equal(t, uint64(1), stats.CloseReasons[CloseReasonPeerClosed])
+	metrics.mu.Lock()
+	assert.Equal(t, []string{RejectReasonTooManySessions, RejectReasonTooManySessions}, metrics.rejected)
+	metrics.mu.Unlock()
+	assert.Nil(t, manager.GetSession(sess.ID()))
+}
+
+func TestServer_Bytes(t *testing.T) {
+	manager := NewManager()
+	server, err := Listen("127.0.0.1:0", "tcp", nil, echoHandler(), 0,
+		WithManager(manager), WithCodexFunc(codex.NewJson))
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	sess, err := DialCodex(server.Addr().String(), "tcp", codex.NewJson, 0, time.Second)
+	require.NoError(t, err)
+	defer sess.Close()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, sess.Send(&wsMessage{ID: i}))
+		require.NoError(t, sess.Receive(&wsMessage{}))
+	}
+	stats := sess.Stats()
+	assert.True(t, stats.BytesSent > 0)
+	assert.Equal(t, stats.BytesSent, stats.BytesReceived)
+
+	// 服务端写入返回之后才计数，可能晚于客户端读到数据
+	require.Eventually(t, func() bool {
+		serverStats := manager.Stats()
+		return serverStats.BytesReceived == stats.BytesSent && serverStats.BytesSent == stats.BytesReceived
+	}, time.Second, 10*time.Millisecond)
+
+	// 可以取到原始连接
+	mc, ok := sess.Conn.(*MeteredConn)
+	require.True(t, ok)
+	_, ok = mc.Unwrap().(*net.TCPConn)
+	assert.True(t, ok)
+}
+
+func TestDial_RawConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// 编解码器不是基于连接创建的，无法统计字节数，会话使用原始连接
+	sess, err := Dial(listener.Addr().String(), "tcp", nil, 0)
+	require.NoError(t, err)
+	defer sess.Close()
+	_, ok := sess.Conn.(*net.TCPConn)
+	assert.True(t, ok)
+
+	sess, err = DialTimeout(listener.Addr().String(), "tcp", nil, 0, time.Second)
+	require.NoError(t, err)
+	defer sess.Close()
+	_, ok = sess.Conn.(*net.TCPConn)
+	assert.True(t, ok)
+}
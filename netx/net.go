@@ -8,13 +8,13 @@ import (
 )
 
 // Listen listens on the network address addr and then calls Serve with handler to handle requests on incoming connections.
-func Listen(addr string, protocol string, code codex.Codex, handler Handler, sendSize int) (*Server, error) {
+func Listen(addr string, protocol string, code codex.Codex, handler Handler, sendSize int, opts ...option.Option[Server]) (*Server, error) {
 	listener, err := net.Listen(protocol, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewServer(listener, code, handler, sendSize), nil
+	return NewServer(listener, code, handler, sendSize, opts...), nil
 }
 
 // Dial connects to the address on the named network.
@@ -44,3 +44,20 @@ func DialTimeout(addr string, protocol string, code codex.Codex, sendSize int, t
 	}
 	return NewSession(code, conn, opts...), nil
 }
+
+// DialCodex connects to the address on the named network with a timeout,
+// the codec is created from a MeteredConn wrapping the connection so that the session can count bytes.
+// Use MeteredConn.Unwrap to get the underlying connection. A zero timeout means no timeout.
+func DialCodex(addr string, protocol string, newCodex NewCodexFunc, sendSize int, timeout time.Duration) (*Session, error) {
+	conn, err := net.DialTimeout(protocol, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make([]option.Option[Session], 0)
+	if sendSize > 0 {
+		opts = append(opts, WithSendSize(sendSize))
+	}
+	mc := NewMeteredConn(conn)
+	return NewSession(newCodex(mc), mc, opts...), nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/shijting/kit"
 	"github.com/shijting/kit/codex"
+	"github.com/shijting/kit/option"
 	"io"
 	"net"
 	"strings"
@@ -12,14 +13,42 @@ import (
 
 type Server struct {
 	net.Listener
-	manager      *Manager
-	codex        codex.Codex
+	manager *Manager
+	codex   codex.Codex
+	// newCodex 非空时为每个连接创建各自的编解码器，替代 codex
+	newCodex     NewCodexFunc
 	handler      Handler
 	sendChanSize int
 }
 
-func NewServer(listener net.Listener, code codex.Codex, handler Handler, sendChanSize int) *Server {
-	return &Server{Listener: listener, codex: code, handler: handler, sendChanSize: sendChanSize, manager: NewManager()}
+func NewServer(listener net.Listener, code codex.Codex, handler Handler, sendChanSize int, opts ...option.Option[Server]) *Server {
+	s := &Server{Listener: listener, codex: code, handler: handler, sendChanSize: sendChanSize}
+	option.Options[Server](opts).Apply(s)
+	if s.manager == nil {
+		s.manager = NewManager()
+	}
+	return s
+}
+
+// WithManager 设置管理会话的 Manager，可用于配置指标上报和最大会话数
+func WithManager(manager *Manager) option.Option[Server] {
+	return func(s *Server) {
+		s.manager = manager
+	}
+}
+
+// WithCodexFunc 设置为每个连接创建编解码器的函数。
+// 连接被包装为 MeteredConn，编解码器基于它创建，会话的 BytesSent/BytesReceived 才有数据，
+// 需要原始连接时调用 MeteredConn.Unwrap。未设置时会话使用原始连接，不统计字节数。
+func WithCodexFunc(newCodex NewCodexFunc) option.Option[Server] {
+	return func(s *Server) {
+		s.newCodex = newCodex
+	}
+}
+
+// Manager 返回管理当前所有 Session 的 Manager
+func (s *Server) Manager() *Manager {
+	return s.manager
 }
 
 func (s *Server) Serve() error {
@@ -29,7 +58,16 @@ func (s *Server) Serve() error {
 			return err
 		}
 
-		sess := s.manager.NewSession(conn, s.codex, s.sendChanSize)
+		code := s.codex
+		if s.newCodex != nil {
+			// 只有编解码器基于 MeteredConn 创建时才能统计字节数
+			mc := NewMeteredConn(conn)
+			conn, code = mc, s.newCodex(mc)
+		}
+		sess, err := s.manager.Accept(conn, code, s.sendChanSize)
+		if err != nil {
+			continue
+		}
 		go func() {
 			s.handler.HandleSession(sess)
 		}()
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/shijting/kit/codex"
 	"github.com/shijting/kit/option"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -38,7 +39,15 @@ type Session struct {
 	closeCh   chan struct{}
 	closeMu   sync.Mutex
 
-	State atomic.Value
+	// attrs 会话属性，通过 Key 读写
+	attrs attributes
+
+	metrics          Metrics
+	closeReason      CloseReason
+	bytesReceived    uint64
+	bytesSent        uint64
+	messagesReceived uint64
+	messagesSent     uint64
 
 	//	关闭回调函数
 	closeCallbacks []CloseHandler
@@ -55,10 +64,23 @@ func newSession(codex codex.Codex, conn net.Conn, opts ...option.Option[Session]
 		closeCh:        make(chan struct{}),
 		closeCallbacks: make([]CloseHandler, 0),
 		id:             atomic.AddUint64(&globalSessionId, 1),
+		metrics:        NopMetrics{},
 	}
 
 	option.Options[Session](opts).Apply(sess)
 
+	if mc, ok := conn.(*MeteredConn); ok {
+		mc.onRead = func(n int) {
+			atomic.AddUint64(&sess.bytesReceived, uint64(n))
+			sess.metrics.BytesReceived(sess, n)
+		}
+		mc.onWrite = func(n int) {
+			atomic.AddUint64(&sess.bytesSent, uint64(n))
+			sess.metrics.BytesSent(sess, n)
+		}
+	}
+	sess.metrics.SessionOpened(sess)
+
 	go sess.sendLoop()
 	return sess
 }
@@ -69,6 +91,13 @@ func WithSendSize(size int) option.Option[Session] {
 	}
 }
 
+// WithSessionMetrics 设置会话的指标上报
+func WithSessionMetrics(metrics Metrics) option.Option[Session] {
+	return func(s *Session) {
+		s.metrics = metrics
+	}
+}
+
 func (s *Session) ID() uint64 {
 	return s.id
 }
@@ -80,6 +109,25 @@ func (s *Session) IsClosed() bool {
 func (s *Session) Addr() string {
 	return s.RemoteAddr().String()
 }
+
+// Stats 返回会话的统计快照
+func (s *Session) Stats() SessionStats {
+	return SessionStats{
+		BytesReceived:    atomic.LoadUint64(&s.bytesReceived),
+		BytesSent:        atomic.LoadUint64(&s.bytesSent),
+		MessagesReceived: atomic.LoadUint64(&s.messagesReceived),
+		MessagesSent:     atomic.LoadUint64(&s.messagesSent),
+		SendQueueDepth:   len(s.sendCh),
+	}
+}
+
+// CloseReason 返回会话关闭的原因，会话未关闭时返回空字符串
+func (s *Session) CloseReason() CloseReason {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	return s.closeReason
+}
+
 func (s *Session) sendLoop() {
 	if s.sendCh == nil {
 		return
@@ -91,13 +139,34 @@ func (s *Session) sendLoop() {
 		case <-s.closeCh:
 			return
 		case msg, ok := <-s.sendCh:
-			if !ok || (s.codex.Send(msg)) != nil {
+			if !ok {
+				return
+			}
+			s.metrics.SendQueueDepth(s, len(s.sendCh))
+			if s.send(msg) != nil {
 				return
 			}
 		}
 	}
 }
 
+// send 通过编解码器发送消息，失败时关闭会话
+func (s *Session) send(msg any) error {
+	err := s.codex.Send(msg)
+	if err != nil {
+		reason := CloseReasonWriteError
+		if isCodecError(err) {
+			reason = CloseReasonCodecError
+			s.metrics.CodecError(s, err)
+		}
+		s.closeWithReason(reason)
+		return err
+	}
+	atomic.AddUint64(&s.messagesSent, 1)
+	s.metrics.MessageSent(s)
+	return nil
+}
+
 func (s *Session) Send(msg any) error {
 	if s.sendCh != nil {
 
@@ -110,6 +179,7 @@ func (s *Session) Send(msg any) error {
 
 		select {
 		case s.sendCh <- msg:
+			s.metrics.SendQueueDepth(s, len(s.sendCh))
 			return nil
 		default:
 			//  send chan full
@@ -124,11 +194,7 @@ func (s *Session) Send(msg any) error {
 		return ErrSessionClosed
 	}
 
-	err := s.codex.Send(msg)
-	if err != nil {
-		s.Close()
-	}
-	return err
+	return s.send(msg)
 }
 
 func (s *Session) Receive(a any) error {
@@ -139,22 +205,42 @@ func (s *Session) Receive(a any) error {
 	}
 	err := s.codex.Receive(a)
 	if err != nil {
-		s.Close()
+		reason := CloseReasonReadError
+		switch {
+		case errors.Is(err, io.EOF):
+			reason = CloseReasonPeerClosed
+		case isCodecError(err):
+			reason = CloseReasonCodecError
+			s.metrics.CodecError(s, err)
+		}
+		s.closeWithReason(reason)
+		return err
 	}
-	return err
+	atomic.AddUint64(&s.messagesReceived, 1)
+	s.metrics.MessageReceived(s)
+	return nil
 }
 
 func (s *Session) Close() error {
+	s.closeWithReason(CloseReasonNormal)
+	return nil
+}
+
+func (s *Session) closeWithReason(reason CloseReason) {
 	if atomic.CompareAndSwapInt32(&s.closeFlag, 0, 1) {
+		s.closeMu.Lock()
+		s.closeReason = reason
+		s.closeMu.Unlock()
+
 		// 执行关闭回调函数
 		for _, callback := range s.closeCallbacks {
 			callback(s)
 		}
-		s.closeFlag = 1
 		s.Conn.Close()
 		close(s.closeCh)
+		s.attrs.clear()
+		s.metrics.SessionClosed(s, reason)
 	}
-	return nil
 }
 
 func (s *Session) AddCloseCallback(callback CloseHandler) {
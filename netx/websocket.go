@@ -0,0 +1,275 @@
+package netx
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/shijting/kit/codex"
+	"github.com/shijting/kit/option"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	_ net.Conn = (*WsConn)(nil)
+	// ErrWsUnsupportedMessage 收到了非数据帧（text/binary）以外的消息
+	ErrWsUnsupportedMessage = errors.New("unsupported websocket message type")
+)
+
+// NewCodexFunc 根据连接创建编解码器，每个连接各自持有一个编解码器
+type NewCodexFunc func(rw io.ReadWriter) codex.Codex
+
+// WsConn 将 websocket 连接适配为 net.Conn，
+// 写入的每一段数据作为一条 websocket 消息发送，读取时将多条消息拼接成字节流。
+type WsConn struct {
+	conn *websocket.Conn
+	// messageType 发送消息的类型，默认为 websocket.BinaryMessage
+	messageType int
+	// pingInterval 发送 ping 的间隔，为 0 时不主动发送 ping
+	pingInterval time.Duration
+	// pongWait 读超时时间，每次收到 pong 或者新的消息时延长，超时未收到任何消息则读取失败
+	pongWait time.Duration
+
+	readMu  sync.Mutex
+	reader  io.Reader
+	writeMu sync.Mutex
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewWsConn 创建 WsConn 实例。
+func NewWsConn(conn *websocket.Conn, opts ...option.Option[WsConn]) *WsConn {
+	c := &WsConn{
+		conn:        conn,
+		messageType: websocket.BinaryMessage,
+		closeCh:     make(chan struct{}),
+	}
+	option.Options[WsConn](opts).Apply(c)
+
+	if c.pongWait > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		})
+	}
+	if c.pingInterval > 0 {
+		go c.pingLoop()
+	}
+	return c
+}
+
+// WithWsMessageType 设置发送消息的类型，websocket.TextMessage 或 websocket.BinaryMessage
+func WithWsMessageType(messageType int) option.Option[WsConn] {
+	return func(c *WsConn) {
+		c.messageType = messageType
+	}
+}
+
+// WithWsPingInterval 设置发送 ping 的间隔
+func WithWsPingInterval(interval time.Duration) option.Option[WsConn] {
+	return func(c *WsConn) {
+		c.pingInterval = interval
+	}
+}
+
+// WithWsPongWait 设置读超时时间，收到 pong 或者新的消息时延长，应大于对端发送消息或者本端 ping 的间隔
+func WithWsPongWait(wait time.Duration) option.Option[WsConn] {
+	return func(c *WsConn) {
+		c.pongWait = wait
+	}
+}
+
+func (c *WsConn) pingLoop() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-ticker.C:
+			// WriteControl 可以和其他写方法并发调用
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.pingInterval))
+			if err != nil {
+				_ = c.Close()
+				return
+			}
+		}
+	}
+}
+
+// Read 读取数据，当前消息读完后自动切换到下一条消息
+func (c *WsConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for {
+		if c.reader == nil {
+			messageType, reader, err := c.conn.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage && messageType != websocket.TextMessage {
+				return 0, ErrWsUnsupportedMessage
+			}
+			if c.pongWait > 0 {
+				// 数据帧同样说明连接存活
+				_ = c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+			}
+			c.reader = reader
+		}
+
+		n, err := c.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			c.reader = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Write 将 p 作为一条消息写出
+func (c *WsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.WriteMessage(c.messageType, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 发送关闭帧并关闭底层连接
+func (c *WsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *WsConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *WsConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *WsConn) SetDeadline(t time.Time) error {
+	if err := c.conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *WsConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *WsConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// WsServer 将 HTTP 升级请求转换为 Session，可直接作为 http.Handler 使用
+type WsServer struct {
+	upgrader     websocket.Upgrader
+	manager      *Manager
+	newCodex     NewCodexFunc
+	handler      Handler
+	sendChanSize int
+	connOpts     []option.Option[WsConn]
+}
+
+// NewWsServer 创建 WsServer 实例。
+func NewWsServer(newCodex NewCodexFunc, handler Handler, sendChanSize int, opts ...option.Option[WsServer]) *WsServer {
+	s := &WsServer{
+		newCodex:     newCodex,
+		handler:      handler,
+		sendChanSize: sendChanSize,
+	}
+	option.Options[WsServer](opts).Apply(s)
+	if s.manager == nil {
+		s.manager = NewManager()
+	}
+	return s
+}
+
+// WithWsManager 设置管理会话的 Manager，可用于配置指标上报和最大会话数
+func WithWsManager(manager *Manager) option.Option[WsServer] {
+	return func(s *WsServer) {
+		s.manager = manager
+	}
+}
+
+// WithWsUpgrader 设置 websocket 升级器，可用于配置缓冲区大小、跨域检查等
+func WithWsUpgrader(upgrader websocket.Upgrader) option.Option[WsServer] {
+	return func(s *WsServer) {
+		s.upgrader = upgrader
+	}
+}
+
+// WithWsConnOptions 设置升级后连接的选项
+func WithWsConnOptions(opts ...option.Option[WsConn]) option.Option[WsServer] {
+	return func(s *WsServer) {
+		s.connOpts = opts
+	}
+}
+
+// Manager 返回管理当前所有 Session 的 Manager
+func (s *WsServer) Manager() *Manager {
+	return s.manager
+}
+
+// ServeHTTP 升级连接并调用 handler 处理 Session
+func (s *WsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 失败时已经写回了错误响应
+		s.manager.metrics.ConnRejected(RejectReasonUpgradeFailed)
+		return
+	}
+
+	conn := NewMeteredConn(NewWsConn(ws, s.connOpts...))
+	sess, err := s.manager.Accept(conn, s.newCodex(conn), s.sendChanSize)
+	if err != nil {
+		return
+	}
+	s.handler.HandleSession(sess)
+}
+
+// GinHandler 返回一个 gin 处理函数，用于在 gin 路由上挂载 websocket 服务
+func (s *WsServer) GinHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s.ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
+
+// DialWs 连接 websocket 服务端。
+// url: 例如 ws://127.0.0.1:8080/ws
+func DialWs(url string, newCodex NewCodexFunc, sendSize int, opts ...option.Option[WsConn]) (*Session, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := NewMeteredConn(NewWsConn(ws, opts...))
+	sessOpts := make([]option.Option[Session], 0)
+	if sendSize > 0 {
+		sessOpts = append(sessOpts, WithSendSize(sendSize))
+	}
+	return NewSession(newCodex(conn), conn, sessOpts...), nil
+}
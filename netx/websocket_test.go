@@ -0,0 +1,122 @@
+package netx
+
+import (
+	"github.com/shijting/kit/codex"
+	"github.com/shijting/kit/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type wsMessage struct {
+	ID   int
+	Text string
+}
+
+func echoHandler() Handler {
+	return HandlerFunc(func(sess *Session) {
+		for {
+			var msg wsMessage
+			if err := sess.Receive(&msg); err != nil {
+				return
+			}
+			if err := sess.Send(&msg); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestWsServer(t *testing.T) {
+	testCases := []struct {
+		name       string
+		serverOpts []option.Option[WsConn]
+		sendSize   int
+		wait       time.Duration
+	}{
+		{
+			name: "echo",
+		},
+		{
+			name:     "echo with send channel",
+			sendSize: 8,
+		},
+		{
+			name:       "keep alive by ping pong",
+			serverOpts: []option.Option[WsConn]{WithWsPingInterval(20 * time.Millisecond), WithWsPongWait(60 * time.Millisecond)},
+			wait:       200 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewWsServer(codex.NewJson, echoHandler(), tc.sendSize, WithWsConnOptions(tc.serverOpts...))
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+			defer server.Manager().Close()
+
+			sess, err := DialWs("ws"+strings.TrimPrefix(ts.URL, "http"), codex.NewJson, tc.sendSize)
+			require.NoError(t, err)
+			defer sess.Close()
+
+			for i := 0; i < 3; i++ {
+				if tc.wait > 0 {
+					// 客户端在读取时自动回复 pong，服务端的读超时会被不断延长
+					go func() {
+						time.Sleep(tc.wait)
+						_ = sess.Send(&wsMessage{ID: -1})
+					}()
+					var ping wsMessage
+					require.NoError(t, sess.Receive(&ping))
+					assert.Equal(t, -1, ping.ID)
+				}
+
+				want := &wsMessage{ID: i, Text: "hello"}
+				require.NoError(t, sess.Send(want))
+				got := &wsMessage{}
+				require.NoError(t, sess.Receive(got))
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestWsConn_PongWaitWithTraffic(t *testing.T) {
+	// 服务端不发送 ping，持续收到消息时读超时同样被延长
+	server := NewWsServer(codex.NewJson, echoHandler(), 0,
+		WithWsConnOptions(WithWsPongWait(60*time.Millisecond)))
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	defer server.Manager().Close()
+
+	sess, err := DialWs("ws"+strings.TrimPrefix(ts.URL, "http"), codex.NewJson, 0)
+	require.NoError(t, err)
+	defer sess.Close()
+
+	for i := 0; i < 10; i++ {
+		time.Sleep(20 * time.Millisecond)
+		want := &wsMessage{ID: i, Text: "hello"}
+		require.NoError(t, sess.Send(want))
+		got := &wsMessage{}
+		require.NoError(t, sess.Receive(got))
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestWsConnClose(t *testing.T) {
+	server := NewWsServer(codex.NewJson, HandlerFunc(func(sess *Session) {
+		_ = sess.Close()
+	}), 0)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	sess, err := DialWs("ws"+strings.TrimPrefix(ts.URL, "http"), codex.NewJson, 0)
+	require.NoError(t, err)
+
+	var msg wsMessage
+	assert.Error(t, sess.Receive(&msg))
+	assert.True(t, sess.IsClosed())
+}
@@ -0,0 +1,121 @@
+package syncx
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrGoexit fn 调用了 runtime.Goexit 时等待中的调用得到的错误
+var ErrGoexit = errors.New("singleflight: runtime.Goexit was called")
+
+// PanicError fn panic 时等待中的调用得到的错误
+type PanicError struct {
+	// Value recover 得到的值
+	Value any
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Group 进程内的 singleflight，相同 key 的并发调用只执行一次 fn，其他调用共享结果
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Result DoChan 返回的结果
+type Result[V any] struct {
+	Val    V
+	Err    error
+	Shared bool
+}
+
+type call[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+	// chans DoChan 的调用方等待结果的 channel
+	chans []chan<- Result[V]
+}
+
+// Do 执行 fn 并返回结果，shared 表示结果是否被多个调用共享。
+// fn panic 时等待中的调用得到 *PanicError，执行 fn 的调用重新 panic。
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := new(call[V])
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn, true)
+	return c.val, c.err, false
+}
+
+// DoChan 与 Do 相同，但是不阻塞，结果通过返回的 channel 发送，调用方可以不再等待。
+// fn 在新的 goroutine 中执行，panic 时所有调用方都得到 *PanicError。
+func (g *Group[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call[V]{chans: []chan<- Result[V]{ch}}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn, false)
+	return ch
+}
+
+// doCall 执行 fn 并把结果交给等待中的调用，repanic 为 true 时在 fn panic 之后重新 panic
+func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error), repanic bool) {
+	normalReturn := false
+	var recovered any
+	defer func() {
+		if !normalReturn && recovered == nil {
+			c.err = ErrGoexit
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		chans := c.chans
+		g.mu.Unlock()
+		c.wg.Done()
+		for _, ch := range chans {
+			ch <- Result[V]{Val: c.val, Err: c.err, Shared: len(chans) > 1}
+		}
+		if recovered != nil && repanic {
+			panic(recovered)
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				if r := recover(); r != nil {
+					recovered = r
+					c.err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}
+		}()
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+}
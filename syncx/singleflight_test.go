@@ -0,0 +1,113 @@
+package syncx
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var g Group[string, int]
+	var calls int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do("key", func() (int, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return 42, nil
+			})
+			if err != nil || v != 42 {
+				t.Errorf("Expected 42, got %v, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %v", calls)
+	}
+
+	// 调用结束后再次调用会重新执行
+	wantErr := errors.New("load error")
+	_, err, shared := g.Do("key", func() (int, error) {
+		return 0, wantErr
+	})
+	if err != wantErr || shared {
+		t.Errorf("Expected %v and not shared, got %v, %v", wantErr, err, shared)
+	}
+}
+
+func TestGroup_Panic(t *testing.T) {
+	var g Group[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+	waiter := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected leader to panic with boom, got %v", r)
+			}
+			close(waiter)
+		}()
+		_, _, _ = g.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	result := make(chan error, 1)
+	go func() {
+		_, err, _ := g.Do("key", func() (int, error) {
+			return 0, nil
+		})
+		result <- err
+	}()
+	// 等待第二个调用加入
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-waiter
+
+	var pe *PanicError
+	if err := <-result; !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Errorf("Expected PanicError, got %v", err)
+	}
+}
+
+func TestGroup_DoChan(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+	ch1 := g.DoChan("key", fn)
+	ch2 := g.DoChan("key", fn)
+	close(release)
+	for _, ch := range []<-chan Result[int]{ch1, ch2} {
+		res := <-ch
+		if res.Val != 42 || res.Err != nil || !res.Shared {
+			t.Errorf("Expected shared 42, got %+v", res)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %v", calls)
+	}
+
+	// DoChan 中 fn panic 不会导致进程退出，调用方得到 PanicError
+	res := <-g.DoChan("key", func() (int, error) {
+		panic("boom")
+	})
+	var pe *PanicError
+	if !errors.As(res.Err, &pe) {
+		t.Errorf("Expected PanicError, got %v", res.Err)
+	}
+}